@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+// format normalises documents by fusing them back, writing them in place.
+func format(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	id := flags.String("lang", "", "language of the documents, guessed from the extension or the content by default")
+	diff := flags.Bool("diff", false, "print the differences instead of writing the documents")
+	flags.BoolVar(&trim, "trim", false, "strip trailing whitespace from every line")
+	filenames := interleaved(flags, args)
+	if len(filenames) == 0 {
+		exit("fmt: no document given")
+	}
+
+	for _, filename := range filenames {
+		original, err := ioutil.ReadFile(filename)
+		nofail(err)
+		lang, parsed := load(*id, filename)
+		formatted := bytes.Buffer{}
+		nofail(lang.FuseWriter(parsed, &formatted))
+		if bytes.Equal(original, formatted.Bytes()) {
+			continue
+		}
+
+		if *diff {
+			nofail(printDiff(filename, formatted.Bytes()))
+			continue
+		}
+		info, err := os.Stat(filename)
+		nofail(err)
+		nofail(ioutil.WriteFile(filename, formatted.Bytes(), info.Mode().Perm()))
+	}
+}
+
+// printDiff prints the unified diff between a file and its formatted content,
+// using the diff command like gofmt used to.
+func printDiff(filename string, formatted []byte) error {
+	tmp, err := ioutil.TempFile("", "litorg")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := tmp.Write(formatted); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("diff", "-u", "--label", filename, "--label", filename+".fmt", filename, tmp.Name())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return nil // Differences were found.
+	}
+	if err != nil {
+		return fmt.Errorf("diff %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatIdempotent(t *testing.T) {
+	document := strings.Join([]string{
+		"* Title :tag:",
+		"#+BEGIN_SRC sh   :exports    none",
+		"echo hello",
+		"#+END_SRC",
+		"",
+	}, "\n")
+	filename := filepath.Join(t.TempDir(), "document.org")
+	if err := os.WriteFile(filename, []byte(document), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	format([]string{filename})
+	once, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(once) == document {
+		t.Fatalf("formatting did not normalise the document:\n%s", once)
+	}
+
+	format([]string{filename})
+	twice, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(twice) != string(once) {
+		t.Errorf("formatting twice gives:\n%s\nformatting once gives:\n%s", twice, once)
+	}
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/mooss/litlib/parse"
 )
 
+// separator is printed between the documents when several files are given.
+const separator = "-----"
+
+// exit reports an error on stderr and exits with a failure status.
 func exit(msg string) {
 	fmt.Fprintln(os.Stderr, msg)
-	os.Exit(0)
+	os.Exit(1)
 }
 
 func nofail(err error) {
@@ -21,21 +27,117 @@ func nofail(err error) {
 	}
 }
 
-func main() {
-	flag.Parse()
-	if flag.NArg() != 1 {
-		exit(fmt.Sprint("Usage: ", os.Args[0], " filename"))
+// trim strips trailing whitespace from the lines of the documents when set.
+var trim = false
+
+// language returns the language of a file, the one given by the lang flag
+// taking precedence over the extension.
+// The language of the standard input and of files without extension is
+// guessed from their content.
+func language(id, filename string, lines []string) parse.Language {
+	if id != "" {
+		lang, found := parse.Languages.ByIdentifier(id)
+		if !found {
+			exit(fmt.Sprint("Unknown language ", id))
+		}
+		return lang
+	}
+	if filename == "" || filepath.Ext(filename) == "" {
+		lang, _ := parse.DetectLanguage(lines, parse.Languages)
+		return lang
+	}
+	lang, found := parse.Languages.ByExtension(filename)
+	if !found {
+		exit(fmt.Sprint("Unknown language for ", filename))
 	}
+	return lang
+}
 
-	filename := flag.Arg(0)
-	content, err := ioutil.ReadFile(filename)
+// load parses a document, an empty filename meaning the standard input.
+func load(id, filename string) (parse.Language, parse.Elements) {
+	var content []byte
+	var err error
+	if filename == "" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(filename)
+	}
 	nofail(err)
 
-	parsed, err := parse.OrgLang.Parse(strings.Split(string(content), "\n"))
+	lines := strings.Split(string(content), "\n")
+	lang := language(id, filename, lines)
+	if trim {
+		lang.Parser = lang.Parser.WithBake(parse.TrimRightBk)
+	}
+	parsed, err := lang.Parse(lines)
 	nofail(err)
+	return lang, parsed
+}
 
-	fused, err := parse.OrgLang.Fuse(parsed)
-	nofail(err)
+// process parses a document and outputs it in the given format, an empty
+// filename meaning the standard input.
+func process(id, filename, format string) {
+	lang, parsed := load(id, filename)
+	switch format {
+	case "fuse":
+		nofail(lang.FuseWriter(parsed, os.Stdout))
+	case "json":
+		encoded, err := json.MarshalIndent(parsed, "", "  ")
+		nofail(err)
+		os.Stdout.Write(encoded)
+	case "repr":
+		parsed.Dump()
+	default:
+		exit(fmt.Sprint("Unknown format ", format))
+	}
+}
+
+// interleaved parses flags that can appear after positional arguments,
+// returning the positional arguments.
+func interleaved(flags *flag.FlagSet, args []string) []string {
+	res := []string{}
+	for {
+		nofail(flags.Parse(args))
+		if flags.NArg() == 0 {
+			return res
+		}
+		res = append(res, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "tangle":
+			tangle(os.Args[2:])
+			return
+		case "fmt":
+			format(os.Args[2:])
+			return
+		}
+	}
+
+	id := flag.String("lang", "", "language of the documents, guessed from the extension or the content by default")
+	format := flag.String("format", "fuse", "output format, one of fuse, json and repr")
+	flag.BoolVar(&trim, "trim", false, "strip trailing whitespace from every line")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[-lang language] [-format format] [-trim] [filename...]")
+		fmt.Fprintln(os.Stderr, "       "+os.Args[0], "tangle [-lang language] [-out directory] [-expand] filename...")
+		fmt.Fprintln(os.Stderr, "       "+os.Args[0], "fmt [-lang language] [-diff] [-trim] filename...")
+		fmt.Fprintln(os.Stderr, "Reads the standard input when no filename is given.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	fmt.Print(strings.Join(fused, "\n"))
+	if flag.NArg() == 0 {
+		process(*id, "", *format)
+		return
+	}
+	for i, filename := range flag.Args() {
+		if i > 0 {
+			fmt.Print("\n" + separator + "\n")
+		}
+		process(*id, filename, *format)
+	}
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mooss/litlib/parse"
+)
+
+// tangle writes the code blocks of documents to their tangle targets.
+func tangle(args []string) {
+	flags := flag.NewFlagSet("tangle", flag.ExitOnError)
+	id := flags.String("lang", "", "language of the documents, guessed from the extension or the content by default")
+	out := flags.String("out", ".", "directory in which the files are written")
+	expand := flags.Bool("expand", false, "expand noweb references before tangling")
+	filenames := interleaved(flags, args)
+	if len(filenames) == 0 {
+		exit("tangle: no document given")
+	}
+
+	files := map[string][]string{}
+	for _, filename := range filenames {
+		_, parsed := load(*id, filename)
+		if *expand {
+			var err error
+			parsed, err = parse.ExpandNoweb(parse.AttachAffiliated(parsed))
+			nofail(err)
+		}
+		tangled, err := parse.Tangle(parsed, *out)
+		nofail(err)
+		for path, lines := range tangled {
+			files[path] = append(files[path], lines...)
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	total := 0
+	for _, path := range paths {
+		lines := files[path]
+		nofail(os.MkdirAll(filepath.Dir(path), 0755))
+		nofail(ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+		fmt.Printf("%s: %d lines\n", path, len(lines))
+		total += len(lines)
+	}
+	fmt.Printf("%d files, %d lines\n", len(paths), total)
+}
@@ -0,0 +1,34 @@
+package parse
+
+import "testing"
+
+func TestPipeBake(t *testing.T) {
+	bake := PipeBake(orgPropertyPfx.StripLeftOf, TrimRightBk)
+	if baked := bake("#+title: Document  \t"); baked != "title: Document" {
+		t.Errorf("baked into %q", baked)
+	}
+
+	// Bakers are applied from left to right.
+	exclaim := func(l string) string { return l + "!" }
+	question := func(l string) string { return l + "?" }
+	if baked := PipeBake(exclaim, question)("what"); baked != "what!?" {
+		t.Errorf("baked into %q", baked)
+	}
+	if baked := PipeBake()("same"); baked != "same" {
+		t.Errorf("empty pipe baked into %q", baked)
+	}
+}
+
+func TestRulesWithBake(t *testing.T) {
+	rules := OrgRules.WithBake(TrimRightBk)
+	matter, err := rules.Parse([]string{"#+title: Document  ", "Prose.  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := matter[0].AsMetadata(); meta.Name != "title" || meta.Data.FuseToNoweb() != "Document" {
+		t.Errorf("metadata baked into %v", matter[0].Repr())
+	}
+	if prose, _ := matter[1].AsProse(); prose.Raw[0] != "Prose." {
+		t.Errorf("prose baked into %q", prose.Raw[0])
+	}
+}
@@ -0,0 +1,84 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Unsupported is the policy applied by Convert to the elements that the target
+// language cannot fuse.
+type Unsupported int
+
+const (
+	FailUnsupported    Unsupported = iota // Return the fusing error.
+	DropUnsupported                       // Leave the element out.
+	CommentUnsupported                    // Include the source of the element as a comment.
+)
+
+// ConvertOptions configures Convert.
+type ConvertOptions struct {
+	Unsupported Unsupported
+	Warn        func(string) // Optional, told about each dropped or commented element.
+}
+
+// Convert parses lines with a language and fuses them with another.
+// Whether an element is supported is decided by fusing it after the previous
+// kept element, since some elements, like Markdown metadata, are only valid in
+// certain places.
+func Convert(from, to Language, lines []string, opts ConvertOptions) ([]string, error) {
+	matter, err := from.Parse(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	res := Elements{}
+	for _, part := range matter {
+		context := Elements{part}
+		if len(res) > 0 {
+			context = Elements{res[len(res)-1], part}
+		}
+		_, err := to.Fuse(context)
+		var unsupported FuseError
+		if !errors.As(err, &unsupported) {
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, part)
+			continue
+		}
+
+		warn := func(action string) {
+			if opts.Warn != nil {
+				opts.Warn(fmt.Sprintf("line %d: %s, %s", part.Span.StartLine, unsupported, action))
+			}
+		}
+		switch opts.Unsupported {
+		case DropUnsupported:
+			warn("dropped")
+
+		case CommentUnsupported:
+			if to.Comment == nil {
+				return nil, fmt.Errorf("%w and comments are not supported either", unsupported)
+			}
+			source, err := convertSource(from, lines, part)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, Element{ElementImpl: to.Comment(source), Span: part.Span})
+			warn("commented")
+
+		default:
+			return nil, unsupported
+		}
+	}
+	return to.Fuse(res)
+}
+
+// convertSource returns the lines an element was parsed from, or fuses it
+// again when its span is unknown.
+func convertSource(from Language, lines []string, part Element) ([]string, error) {
+	if part.Span.StartLine > 0 && part.Span.EndLine <= len(lines) {
+		return lines[part.Span.StartLine-1 : part.Span.EndLine], nil
+	}
+	return from.Fuse(Elements{part})
+}
@@ -0,0 +1,126 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Change is a difference between two documents, at the level of their
+// elements.
+type Change struct {
+	Op  string  // One of insert, delete and modify.
+	A   int     // Index of the element in the first document, -1 for insertions.
+	B   int     // Index of the element in the second document, -1 for deletions.
+	Old Element // Element of the first document, zero for insertions.
+	New Element // Element of the second document, zero for deletions.
+}
+
+// String describes the change, like "code `setup` modified".
+func (c Change) String() string {
+	part := c.New
+	switch {
+	case c.Op == "delete":
+		part = c.Old
+	case c.Op == "modify" && diffLabel(c.Old) != diffLabel(c.New):
+		return fmt.Sprintf("%s `%s` modified into `%s`", part.TypeName(), diffLabel(c.Old), diffLabel(c.New))
+	}
+	participles := map[string]string{"insert": "inserted", "delete": "deleted", "modify": "modified"}
+	return fmt.Sprintf("%s `%s` %s", part.TypeName(), diffLabel(part), participles[c.Op])
+}
+
+// diffLabel returns what identifies an element to a reader, i.e. the title of
+// sections, the name of metadata and code and the summary of other elements.
+func diffLabel(part Element) string {
+	if section, ok := part.AsSection(); ok {
+		return section.Title
+	}
+	if meta, ok := part.AsMetadata(); ok {
+		return meta.Name
+	}
+	if code, ok := part.AsCode(); ok {
+		if name := nowebName(part, code); name != "" {
+			return name
+		}
+	}
+	return yamlSummary(part)
+}
+
+// diffSignature returns what must be equal for two elements to be identical.
+func diffSignature(part Element) string {
+	return part.TypeName() + "\n" + part.Name + "\n" + strings.Join(part.Repr(), "\n")
+}
+
+// Diff returns the changes turning a into b, in document order.
+// The elements are compared by representation, with a longest common
+// subsequence, and the deletions and insertions of elements of the same kind
+// between two common elements are paired as modifications.
+// Spans are not compared, so moving an element only changes its neighbours.
+func Diff(a, b Elements) []Change {
+	sa, sb := Map(diffSignature, a), Map(diffSignature, b)
+
+	// common[i][j] is the length of the longest common subsequence of sa[i:] and sb[j:].
+	common := make([][]int, len(sa)+1)
+	for i := range common {
+		common[i] = make([]int, len(sb)+1)
+	}
+	for i := len(sa) - 1; i >= 0; i-- {
+		for j := len(sb) - 1; j >= 0; j-- {
+			if sa[i] == sb[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	res := []Change{}
+	deleted, inserted := []int{}, []int{} // Changes since the last common element.
+	flush := func() {
+		res = append(res, diffPair(a, b, deleted, inserted)...)
+		deleted, inserted = deleted[:0], inserted[:0]
+	}
+	i, j := 0, 0
+	for i < len(sa) || j < len(sb) {
+		switch {
+		case i < len(sa) && j < len(sb) && sa[i] == sb[j]:
+			flush()
+			i++
+			j++
+		case j == len(sb) || (i < len(sa) && common[i+1][j] >= common[i][j+1]):
+			deleted = append(deleted, i)
+			i++
+		default:
+			inserted = append(inserted, j)
+			j++
+		}
+	}
+	flush()
+	return res
+}
+
+// diffPair makes the changes of deleted and inserted elements occurring between
+// the same common elements, pairing those of the same kind in order.
+// Deletions and modifications come first, in the order of the first document.
+func diffPair(a, b Elements, deleted, inserted []int) []Change {
+	res := []Change{}
+	paired := make([]bool, len(inserted))
+	for _, i := range deleted {
+		change := Change{Op: "delete", A: i, B: -1, Old: a[i]}
+		for k, j := range inserted {
+			if !paired[k] && a[i].TypeName() == b[j].TypeName() {
+				paired[k] = true
+				change.Op, change.B, change.New = "modify", j, b[j]
+				break
+			}
+		}
+		res = append(res, change)
+	}
+	for k, j := range inserted {
+		if !paired[k] {
+			res = append(res, Change{Op: "insert", A: -1, B: j, New: b[j]})
+		}
+	}
+	return res
+}
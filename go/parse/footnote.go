@@ -0,0 +1,111 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+///////////////////
+// Text matching //
+///////////////////
+
+var orgFootnoteRe = re(`^\[fn:([^\]\s]+)\](?:[ \t]+(.*))?$`)
+var markdownFootnoteRe = re(`^\[\^([^\]\s]+)\]:(?:[ \t]+(.*))?$`)
+
+// footnoteReferenceRe matches Markdown references, Org references and Org
+// inline definitions, whose labels are captured in the first, second and third
+// groups respectively.
+var footnoteReferenceRe = re(`\[\^([^\]\s]+)\]|\[fn:([^\]:\s]+)\]|\[fn:([^\]:\s]+):[^\]]*\]`)
+
+///////////////////////
+// Takers and makers //
+///////////////////////
+
+// FootnoteTake builds a Taker function that will take a footnote definition
+// and the non-blank lines following it that satisfy continuation, up to the
+// next definition.
+func FootnoteTake(def, continuation Pred[string]) Taker {
+	return func(lines []string) int {
+		if len(lines) == 0 || !def(lines[0]) {
+			return 0
+		}
+		return 1 + GreedyTake(And(continuation, Nor(spaces.Intersects, def)))(lines[1:])
+	}
+}
+
+// ReFootnoteMake generates a footnote Maker with a regexp that produces two
+// groups on the first line:
+//   - The label of the footnote.
+//   - The first line of its body.
+//
+// The following lines are kept verbatim in the body.
+func ReFootnoteMake(r regex) Maker {
+	return func(lines []string) ElementImpl {
+		groups := r.Groups(lines[0])
+		return FootnoteElement{
+			Label: groups[1],
+			Body:  *pslc(groups[2]).Add(lines[1:]...),
+		}
+	}
+}
+
+// fuseFootnote reconstructs the lines of a footnote whose label has already been
+// formatted.
+func fuseFootnote(label string, f FootnoteElement) []string {
+	if len(f.Body) == 0 {
+		return slc(label)
+	}
+	first := label
+	if f.Body[0] != "" {
+		first += " " + f.Body[0]
+	}
+	return *pslc(first).Add(f.Body[1:]...)
+}
+
+////////////////
+// Resolution //
+////////////////
+
+// ResolveFootnotes indexes footnote definitions by label.
+// An error is returned when a label is defined several times or when a label
+// is referenced but never defined, but the index is always complete.
+// Org inline definitions count as definitions, although they are not indexed.
+func ResolveFootnotes(matter Elements) (map[string]FootnoteElement, error) {
+	res := map[string]FootnoteElement{}
+	inline := map[string]bool{}
+	referenced := []string{}
+	problems := []string{}
+
+	for _, part := range matter {
+		if f, ok := part.ElementImpl.(FootnoteElement); ok {
+			if _, found := res[f.Label]; found {
+				problems = append(problems, fmt.Sprintf("duplicate footnote `%s`", f.Label))
+			}
+			res[f.Label] = f
+		}
+		for _, line := range proseLines(part) {
+			for _, groups := range footnoteReferenceRe.FindAllStringSubmatch(line, -1) {
+				switch {
+				case groups[1] != "":
+					referenced = append(referenced, groups[1])
+				case groups[2] != "":
+					referenced = append(referenced, groups[2])
+				default:
+					inline[groups[3]] = true
+				}
+			}
+		}
+	}
+
+	reported := map[string]bool{}
+	for _, label := range referenced {
+		if _, found := res[label]; !found && !inline[label] && !reported[label] {
+			problems = append(problems, fmt.Sprintf("undefined footnote `%s`", label))
+			reported[label] = true
+		}
+	}
+	if len(problems) > 0 {
+		return res, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return res, nil
+}
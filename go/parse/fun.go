@@ -3,8 +3,8 @@ package parse
 // Pred is a predicate for type T.
 type Pred[T any] func(T) bool
 
-// and combines the given predicates into one, using the and logical operator.
-func and[T any](preds ...Pred[T]) Pred[T] {
+// And combines the given predicates into one, using the and logical operator.
+func And[T any](preds ...Pred[T]) Pred[T] {
 	return func(t T) bool {
 		for _, p := range preds {
 			if !p(t) {
@@ -15,8 +15,8 @@ func and[T any](preds ...Pred[T]) Pred[T] {
 	}
 }
 
-// nor combines the given predicates into one, using the nor logical operator.
-func nor[T any](preds ...Pred[T]) Pred[T] {
+// Nor combines the given predicates into one, using the nor logical operator.
+func Nor[T any](preds ...Pred[T]) Pred[T] {
 	return func(t T) bool {
 		for _, p := range preds {
 			if p(t) {
@@ -27,6 +27,24 @@ func nor[T any](preds ...Pred[T]) Pred[T] {
 	}
 }
 
+// Or combines the given predicates into one, using the or logical operator.
+func Or[T any](preds ...Pred[T]) Pred[T] {
+	return func(t T) bool {
+		for _, p := range preds {
+			if p(t) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not negates the given predicate.
+func Not[T any](pred Pred[T]) Pred[T] {
+	return func(t T) bool { return !pred(t) }
+}
+
+// Map returns the results of fun applied to the elements of source.
 func Map[T, U any](fun func(T) U, source []T) []U {
 	res := make([]U, len(source))
 	for i, el := range source {
@@ -34,3 +52,33 @@ func Map[T, U any](fun func(T) U, source []T) []U {
 	}
 	return res
 }
+
+// Filter returns the elements of source satisfying pred, in a new slice.
+func Filter[T any](pred Pred[T], source []T) []T {
+	res := []T{}
+	for _, el := range source {
+		if pred(el) {
+			res = append(res, el)
+		}
+	}
+	return res
+}
+
+// Reduce accumulates the elements of source into a value, starting from init.
+func Reduce[T, A any](fun func(A, T) A, init A, source []T) A {
+	acc := init
+	for _, el := range source {
+		acc = fun(acc, el)
+	}
+	return acc
+}
+
+// FlatMap concatenates the slices obtained by applying fun to the elements of
+// source.
+func FlatMap[T, U any](fun func(T) []U, source []T) []U {
+	res := []U{}
+	for _, el := range source {
+		res = append(res, fun(el)...)
+	}
+	return res
+}
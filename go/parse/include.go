@@ -0,0 +1,168 @@
+package parse
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ResolveIncludes replaces the Org include keywords, like
+// `#+INCLUDE: "chapter.org" :lines "5-10"`, with the elements of the files
+// they reference, read from fsys.
+// Included files are resolved recursively, their paths being relative to the
+// directory of the including file, starting from the root of fsys.
+// The following is supported:
+//   - :lines "a-b", to include lines a to b, b excluded, like in Org.
+//   - file.org::*Title, to include the subtree of the first section so titled.
+//   - :only-contents, to include the subtree without its section.
+//   - src lang and example, to include the file as code or as an example.
+//
+// The spans of the included elements refer to their own file.
+func ResolveIncludes(matter Elements, fsys fs.FS) (Elements, error) {
+	return resolveIncludes(matter, fsys, ".", nil)
+}
+
+// resolveIncludes replaces the include keywords of elements read from a file of
+// dir, chain being the files including them, outermost first.
+func resolveIncludes(matter Elements, fsys fs.FS, dir string, chain []string) (Elements, error) {
+	res := Elements{}
+	for _, part := range matter {
+		meta, ok := part.AsMetadata()
+		if !ok || !strings.EqualFold(meta.Name, "INCLUDE") {
+			res = append(res, part)
+			continue
+		}
+		included, err := includeElements(meta, fsys, dir, chain)
+		if err != nil {
+			if len(chain) == 0 {
+				return nil, fmt.Errorf("include on line %d: %w", part.Span.StartLine, err)
+			}
+			return nil, fmt.Errorf("%s:%d: %w", chain[len(chain)-1], part.Span.StartLine, err)
+		}
+		res = append(res, included...)
+	}
+	return res, nil
+}
+
+// includeElements returns the elements referenced by an include keyword.
+// Its value is parsed as noweb arguments.
+func includeElements(meta MetadataElement, fsys fs.FS, dir string, chain []string) (Elements, error) {
+	params := ParseNowebArguments(orgKeywordValue(meta.Data))
+	args := params.Get("")
+	if args == nil || len(*args) == 0 {
+		return nil, fmt.Errorf("no file to include")
+	}
+	target, search, _ := strings.Cut((*args)[0], "::")
+	file := path.Join(dir, target)
+	for _, including := range chain {
+		if including == file {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(chain, file), " -> "))
+		}
+	}
+
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	if lines[len(lines)-1] == "" { // The final newline ends the last line.
+		lines = lines[:len(lines)-1]
+	}
+	if span := params.Get("lines"); span != nil {
+		if lines, err = includeLines(lines, *span); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(*args) > 1 {
+		switch kind := (*args)[1]; kind {
+		case "src":
+			code := CodeElement{Raw: lines}
+			if len(*args) > 2 {
+				code.Lang = (*args)[2]
+			}
+			return Elements{{ElementImpl: code}}, nil
+		case "example":
+			return Elements{{ElementImpl: ExampleElement{Raw: lines, Style: "block"}}}, nil
+		default:
+			return nil, fmt.Errorf("unsupported include kind `%s`", kind)
+		}
+	}
+
+	matter, err := OrgLang.Parse(lines)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if search != "" {
+		if matter, err = includeSubtree(matter, search, params.Get("only-contents")); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return resolveIncludes(matter, fsys, path.Dir(file), append(chain[:len(chain):len(chain)], file))
+}
+
+// includeLines returns the lines in the range of the :lines parameter, which
+// is like "5-10", from line 5 to 10 excluded, either end being optional.
+func includeLines(lines []string, span Values) ([]string, error) {
+	value, err := span.One()
+	if err != nil {
+		return nil, fmt.Errorf(":lines: %w", err)
+	}
+	first, last, found := strings.Cut(value, "-")
+	if !found {
+		return nil, fmt.Errorf(":lines `%s` is not a range", value)
+	}
+	start, end := 1, len(lines)+1
+	if first != "" {
+		if start, err = strconv.Atoi(first); err != nil {
+			return nil, fmt.Errorf(":lines `%s` is not a range", value)
+		}
+	}
+	if last != "" {
+		if end, err = strconv.Atoi(last); err != nil {
+			return nil, fmt.Errorf(":lines `%s` is not a range", value)
+		}
+	}
+	if start < 1 || end < start {
+		return nil, fmt.Errorf(":lines `%s` is not a valid range", value)
+	}
+	if start > len(lines) {
+		return []string{}, nil
+	}
+	if end > len(lines)+1 {
+		end = len(lines) + 1
+	}
+	return lines[start-1 : end-1], nil
+}
+
+// includeSubtree returns the subtree of the first section titled like the
+// search option, which must be like *Title, without its section when
+// onlyContents is true.
+func includeSubtree(matter Elements, search string, onlyContents *Values) (Elements, error) {
+	if !str("*").IsPrefix(search) {
+		return nil, fmt.Errorf("unsupported search option `%s`", search)
+	}
+	title := str("*").StripLeftOf(search)
+	tree, err := BuildTree(matter)
+	if err != nil {
+		return nil, err
+	}
+	nodes := tree.Select(func(n *Node) bool { return n.Section != nil && n.Section.Title == title })
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no section titled `%s`", title)
+	}
+	res := nodes[0].Flatten()
+	if onlyContents == nil {
+		return res, nil
+	}
+	only, err := onlyContents.Bool()
+	if err != nil {
+		return nil, fmt.Errorf(":only-contents: %w", err)
+	}
+	if only {
+		return res[1:], nil
+	}
+	return res, nil
+}
@@ -0,0 +1,133 @@
+package parse
+
+import "strings"
+
+// Inline is a piece of prose with uniform Org markup.
+type Inline struct {
+	Kind   string // One of text, bold, italic, code, verbatim and link.
+	Text   string // Content without the markup, the description of links.
+	Target string // Target of links.
+}
+
+// MarkupElement is prose whose inline markup is parsed, see
+// Elements.ParseInlineMarkup.
+type MarkupElement struct {
+	Spans []Inline
+}
+
+func (m MarkupElement) Repr() []string {
+	return Map(func(span Inline) string {
+		if span.Kind == "link" {
+			return span.Kind + "=" + span.Text + ", target=" + span.Target
+		}
+		return span.Kind + "=" + span.Text
+	}, m.Spans)
+}
+
+// Lines reconstructs the lines of the prose.
+func (m MarkupElement) Lines() []string {
+	return strings.Split(FuseInline(m.Spans), "\n")
+}
+
+///////////////////
+// Text matching //
+///////////////////
+
+// inlineMarkers associates the kinds of emphasis with their marker.
+var inlineMarkers = map[string]byte{"bold": '*', "italic": '/', "code": '=', "verbatim": '~'}
+
+// inlinePre and inlinePost are the characters that can respectively precede
+// and follow emphasis, along with the start and the end of the text.
+var inlinePre = str(" \t\n-({'\"")
+var inlinePost = str(" \t\n-.,:!?;'\")}[")
+
+var inlineLinkRe = re(`^\[\[([^\]]+)\](?:\[([^\]]+)\])?\]`)
+
+////////////////////
+// Parse and fuse //
+////////////////////
+
+// ParseInline splits prose into pieces of text, emphasis and links.
+// Emphasis must fit on one line and cannot be nested, its content being kept
+// as is.
+func ParseInline(text string) []Inline {
+	res := []Inline{}
+	plain := 0 // Start of the text that is not marked up yet.
+	for i := 0; i < len(text); i++ {
+		size, span := inlineAt(text, i)
+		if size == 0 {
+			continue
+		}
+		if i > plain {
+			res = append(res, Inline{Kind: "text", Text: text[plain:i]})
+		}
+		res = append(res, span)
+		plain = i + size
+		i = plain - 1
+	}
+	if plain < len(text) {
+		res = append(res, Inline{Kind: "text", Text: text[plain:]})
+	}
+	return res
+}
+
+// inlineAt returns the markup starting at index i of text, along with its
+// length, which is zero when there is none.
+func inlineAt(text string, i int) (int, Inline) {
+	if groups := inlineLinkRe.Groups(text[i:]); groups != nil {
+		return len(groups[0]), Inline{Kind: "link", Text: groups[2], Target: groups[1]}
+	}
+
+	marker := text[i]
+	if (i > 0 && !inlinePre.HasRune(rune(text[i-1]))) || i+1 >= len(text) || spaces.HasRune(rune(text[i+1])) {
+		return 0, Inline{}
+	}
+	for kind, candidate := range inlineMarkers {
+		if candidate != marker {
+			continue
+		}
+		for j := i + 2; j < len(text) && text[j] != '\n'; j++ {
+			if text[j] == marker && !spaces.HasRune(rune(text[j-1])) &&
+				(j+1 == len(text) || inlinePost.HasRune(rune(text[j+1]))) {
+				return j + 1 - i, Inline{Kind: kind, Text: text[i+1 : j]}
+			}
+		}
+	}
+	return 0, Inline{}
+}
+
+// FuseInline reconstructs the prose of the pieces returned by ParseInline.
+func FuseInline(spans []Inline) string {
+	res := strings.Builder{}
+	for _, span := range spans {
+		marker, emphasis := inlineMarkers[span.Kind]
+		switch {
+		case span.Kind == "link":
+			res.WriteString("[[" + span.Target + "]")
+			if span.Text != "" {
+				res.WriteString("[" + span.Text + "]")
+			}
+			res.WriteString("]")
+		case emphasis:
+			res.WriteByte(marker)
+			res.WriteString(span.Text)
+			res.WriteByte(marker)
+		default:
+			res.WriteString(span.Text)
+		}
+	}
+	return res.String()
+}
+
+// ParseInlineMarkup replaces prose with markup elements.
+// The given elements are not modified.
+func (ps Elements) ParseInlineMarkup() Elements {
+	res := make(Elements, len(ps))
+	for i, part := range ps {
+		res[i] = part
+		if prose, ok := part.AsProse(); ok {
+			res[i].ElementImpl = MarkupElement{Spans: ParseInline(strings.Join(prose.Raw, "\n"))}
+		}
+	}
+	return res
+}
@@ -0,0 +1,206 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+///////////////////
+// Text matching //
+///////////////////
+
+var latexCommandRe = re(`^\\(?:documentclass|begin|(?:sub){0,2}section)\b`)
+var latexSectionRe = re(`^\\((?:sub){0,2})section(\*?)\{(.*)\}[ \t]*$`)
+var latexBeginMintedRe = re(`^\\begin\{minted\}(?:\[(.*)\])?\{([^}]*)\}[ \t]*$`)
+var latexBeginListingRe = re(`^\\begin\{lstlisting\}(?:\[(.*)\])?[ \t]*$`)
+var latexBeginMintedPfx = str(`\begin{minted}`)
+var latexEndMintedPfx = str(`\end{minted}`)
+var latexBeginListingPfx = str(`\begin{lstlisting}`)
+var latexEndListingPfx = str(`\end{lstlisting}`)
+
+////////////////
+// Primitives //
+////////////////
+
+// ParseLaTeXOptions parses the content of LaTeX optional arguments, like
+// "linenos, frame=lines", into parameters.
+// Commas enclosed in braces do not separate options.
+func ParseLaTeXOptions(source string) Parameters {
+	res := Parameters{}
+	add := func(option string) {
+		option = spaces.Trim(option)
+		if option == "" {
+			return
+		}
+		key, value, found := strings.Cut(option, "=")
+		if found {
+			res.Add(spaces.Trim(key), Values{spaces.Trim(value)})
+		} else {
+			res.Add(key, nil)
+		}
+	}
+
+	depth, start := 0, 0
+	for i, r := range source {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+		case r == ',' && depth == 0:
+			add(source[start:i])
+			start = i + 1
+		}
+	}
+	add(source[start:])
+	return res
+}
+
+// FuseToLaTeX fuses parameters into LaTeX optional arguments, the dual of
+// ParseLaTeXOptions.
+func (ps Parameters) FuseToLaTeX() string {
+	acc := make([]string, len(ps))
+	for i, p := range ps {
+		acc[i] = p.Key
+		if len(p.Values) > 0 {
+			acc[i] += "=" + strings.Join(p.Values, " ")
+		}
+	}
+	return strings.Join(acc, ", ")
+}
+
+////////////
+// Makers //
+////////////
+
+// LaTeXSectionMk makes a section element from a LaTeX sectioning command.
+// Starred sections are marked with the starred parameter.
+func LaTeXSectionMk(lines []string) ElementImpl {
+	groups := latexSectionRe.Groups(lines[0])
+	res := SectionElement{Title: groups[3], Level: len(groups[1])/len("sub") + 1}
+	if groups[2] != "" {
+		res.Params.Add("starred", nil)
+	}
+	return res
+}
+
+// LaTeXMintedMk makes a code element from a minted environment.
+func LaTeXMintedMk(lines []string) ElementImpl {
+	groups := latexBeginMintedRe.Groups(lines[0])
+	if groups == nil { // Malformed begin line, without language.
+		groups = make([]string, 3)
+	}
+	return CodeElement{
+		Raw:    lines[1 : len(lines)-1],
+		Lang:   groups[2],
+		Params: ParseLaTeXOptions(groups[1]),
+	}
+}
+
+// LaTeXListingMk makes a code element from a lstlisting environment.
+// The language is taken from the language option, which is also kept in the
+// parameters so that the environment can be reconstructed.
+func LaTeXListingMk(lines []string) ElementImpl {
+	res := CodeElement{Raw: lines[1 : len(lines)-1], Params: Parameters{}}
+	if groups := latexBeginListingRe.Groups(lines[0]); groups != nil {
+		res.Params = ParseLaTeXOptions(groups[1])
+	}
+	if lang := res.Params.Get("language"); lang != nil && len(*lang) > 0 {
+		res.Lang = (*lang)[0]
+	}
+	return res
+}
+
+///////////////////////////////////
+// High-level parsing and fusing //
+///////////////////////////////////
+
+// LaTeXRules is a sequence of rules able to parse a LaTeX file.
+var LaTeXRules = Rules{
+	Rule{ // Section, hierarchical delimiter of the document.
+		Name: "section",
+		Take: FirstTake(latexSectionRe.Match),
+		Bake: NoBk,
+		Make: LaTeXSectionMk,
+	},
+	Rule{ // Code highlighted by minted.
+		Name: "minted",
+		Take: BetweenTake(latexBeginMintedPfx.IsPrefix, latexEndMintedPfx.IsPrefix),
+		Bake: NoBk,
+		Make: LaTeXMintedMk,
+	},
+	Rule{ // Code highlighted by listings.
+		Name: "listings",
+		Take: BetweenTake(latexBeginListingPfx.IsPrefix, latexEndListingPfx.IsPrefix),
+		Bake: NoBk,
+		Make: LaTeXListingMk,
+	},
+	SpaceRule, // Whitespace, content that can typically be ignored.
+	Rule{ // Prose, content meant for human consumption.
+		Name: "prose",
+		Take: TrailingTake(spaces.Intersects, Nor(
+			latexSectionRe.Match, latexBeginMintedPfx.IsPrefix, latexBeginListingPfx.IsPrefix,
+		)),
+		Bake: NoBk,
+		Make: ProseMk,
+	},
+}
+
+// LaTeXStreamFuser can reconstruct the lines of a LaTeX document from parsed elements.
+// Code without language or with a language option is fused into a lstlisting
+// environment, since minted requires a language.
+func LaTeXStreamFuser(matter Elements, emit Emitter) error {
+	for _, part := range matter {
+		switch p := part.ElementImpl.(type) {
+		case CodeElement:
+			var options string
+			if len(p.Params) > 0 {
+				options = "[" + p.Params.FuseToLaTeX() + "]"
+			}
+			if p.Lang == "" || p.Params.Has("language") {
+				emit(string(latexBeginListingPfx) + options)
+				emit(p.Raw...)
+				emit(string(latexEndListingPfx))
+			} else {
+				emit(string(latexBeginMintedPfx) + options + "{" + p.Lang + "}")
+				emit(p.Raw...)
+				emit(string(latexEndMintedPfx))
+			}
+
+		case ProseElement:
+			emit(p.Raw...)
+
+		case SectionElement:
+			if p.Level < 1 || p.Level > 3 {
+				return fmt.Errorf("no latex section of level %d", p.Level)
+			}
+			command := `\` + strings.Repeat("sub", p.Level-1) + "section"
+			if p.Params.Has("starred") {
+				command += "*"
+			}
+			emit(command + "{" + p.Title + "}")
+
+		case SpaceElement:
+			emit(p.Raw...)
+
+		default:
+			return fuseError("latex", part)
+		}
+	}
+	return nil
+}
+
+// LaTeXFuser is like LaTeXStreamFuser, but returns the lines.
+func LaTeXFuser(matter Elements) ([]string, error) {
+	return CollectFuse(LaTeXStreamFuser, matter)
+}
+
+// LaTeXLang holds information needed to manipulate LaTeX files.
+var LaTeXLang = Language{
+	Identifiers: []string{"latex", "tex"},
+	Extensions:  []string{".tex"},
+	Parser:      LaTeXRules,
+	Fuse:        LaTeXFuser,
+	Stream:      LaTeXStreamFuser,
+	Markers:     latexCommandRe.Match,
+}
@@ -0,0 +1,101 @@
+package parse
+
+///////////////////
+// Text matching //
+///////////////////
+
+var lhsBirdPfx = str(">")
+var lhsBirdSpacePfx = str("> ")
+
+///////////////////////
+// Bakers and makers //
+///////////////////////
+
+// LHSBirdBk strips the bird track (and the space following it) of a code line.
+func LHSBirdBk(line string) string {
+	if lhsBirdSpacePfx.IsPrefix(line) {
+		return lhsBirdSpacePfx.StripLeftOf(line)
+	}
+	return lhsBirdPfx.StripLeftOf(line)
+}
+
+// LHSCodeMk makes a Haskell code element from lines stripped of their bird
+// tracks.
+func LHSCodeMk(lines []string) ElementImpl {
+	return CodeElement{Raw: lines, Lang: "haskell", Params: Parameters{}}
+}
+
+///////////////////////////////////
+// High-level parsing and fusing //
+///////////////////////////////////
+
+// LHSRules is a sequence of rules able to parse a Bird-style literate Haskell
+// file.
+var LHSRules = Rules{
+	Rule{ // Code, content meant for machine consumption.
+		Name: "code",
+		Take: GreedyTake(lhsBirdPfx.IsPrefix),
+		Bake: LHSBirdBk,
+		Make: LHSCodeMk,
+	},
+	SpaceRule, // Whitespace, content that can typically be ignored.
+	Rule{ // Prose, content meant for human consumption.
+		Name: "prose",
+		Take: TrailingTake(spaces.Intersects, Nor(lhsBirdPfx.IsPrefix)),
+		Bake: NoBk,
+		Make: ProseMk,
+	},
+}
+
+// LHSStreamFuser can reconstruct the lines of a Bird-style literate Haskell document
+// from parsed elements.
+// GHC rejects code that is directly adjacent to prose, so a blank line is
+// inserted between them when missing.
+func LHSStreamFuser(matter Elements, emit Emitter) error {
+	var previous ElementImpl
+	for _, part := range matter {
+		switch p := part.ElementImpl.(type) {
+		case CodeElement:
+			if _, ok := previous.(ProseElement); ok {
+				emit("")
+			}
+			for _, line := range p.Raw {
+				if line == "" {
+					emit(string(lhsBirdPfx))
+				} else {
+					emit(string(lhsBirdSpacePfx) + line)
+				}
+			}
+
+		case ProseElement:
+			if _, ok := previous.(CodeElement); ok {
+				emit("")
+			}
+			emit(p.Raw...)
+
+		case SpaceElement:
+			emit(p.Raw...)
+
+		default:
+			return fuseError("lhs", part)
+		}
+		previous = part.ElementImpl
+	}
+	return nil
+}
+
+// LHSFuser is like LHSStreamFuser, but returns the lines.
+func LHSFuser(matter Elements) ([]string, error) {
+	return CollectFuse(LHSStreamFuser, matter)
+}
+
+// LHSLang holds information needed to manipulate Bird-style literate Haskell
+// files.
+var LHSLang = Language{
+	Identifiers: []string{"lhs", "literate-haskell"},
+	Extensions:  []string{".lhs"},
+	Parser:      LHSRules,
+	Fuse:        LHSFuser,
+	Stream:      LHSStreamFuser,
+	Markers:     lhsBirdPfx.IsPrefix,
+}
@@ -0,0 +1,35 @@
+package parse
+
+import "strings"
+
+// linkRe matches Org links, whose URL and optional text are captured in the
+// first and second groups, and Markdown links, whose text and URL are captured
+// in the third and fourth groups.
+// Links can span several lines.
+var linkRe = re(`\[\[([^\]]+)\](?:\[([^\]]+)\])?\]|\[([^\]]*)\]\(\s*([^)\s]+)(?:\s+"[^"]*")?\s*\)`)
+
+// Link is a link found in the prose of a document.
+type Link struct {
+	Text    string // Description of the link, empty when there is none.
+	URL     string
+	Element int // Index of the element containing the link.
+}
+
+// ExtractLinks reports the Org and Markdown links found in prose, in document
+// order.
+// Code is never scanned and the elements are not modified.
+func ExtractLinks(matter Elements) []Link {
+	res := []Link{}
+	oneline := func(s string) string { return strings.Join(spaces.Fields(s), " ") }
+	for i, part := range matter {
+		text := strings.Join(proseLines(part), "\n")
+		for _, groups := range linkRe.FindAllStringSubmatch(text, -1) {
+			if groups[1] != "" {
+				res = append(res, Link{Text: oneline(groups[2]), URL: oneline(groups[1]), Element: i})
+			} else {
+				res = append(res, Link{Text: oneline(groups[3]), URL: groups[4], Element: i})
+			}
+		}
+	}
+	return res
+}
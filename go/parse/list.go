@@ -0,0 +1,168 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Lists share the same syntax in Org and Markdown, except for the elements
+// that can interrupt them.
+
+///////////////////
+// Text matching //
+///////////////////
+
+var listItemRe = re(`^([ \t]*)([-+*]|[0-9]+[.)])(?:[ \t]+(.*))?$`)
+var listCheckboxRe = re(`^\[([ xX-])\](?:[ \t]+|$)`)
+
+// listIndent returns the indentation of a line.
+func listIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// listOrdered returns true if the bullet is a number.
+func listOrdered(bullet string) bool {
+	return bullet != "" && bullet[0] >= '0' && bullet[0] <= '9'
+}
+
+////////////
+// Takers //
+////////////
+
+// ListTake builds a Taker function that will take list items and their
+// continuation lines, which must be more indented than the first item.
+// Unindented lines satisfying stop end the list, even when they look like
+// items.
+// Like with TrailingTake, blank lines are not taken as the last line.
+func ListTake(stop Pred[string]) Taker {
+	return func(lines []string) int {
+		if len(lines) == 0 || !listItemRe.Match(lines[0]) || stop(lines[0]) {
+			return 0
+		}
+		base := listIndent(lines[0])
+		lastCore := 0
+		for i, line := range lines[1:] {
+			switch {
+			case spaces.Intersects(line):
+				continue
+			case listIndent(line) > base || (listItemRe.Match(line) && !stop(line)):
+				lastCore = i + 1
+			default:
+				return lastCore + 1
+			}
+		}
+		return lastCore + 1
+	}
+}
+
+////////////
+// Makers //
+////////////
+
+// ListMk makes a list element from lines taken by ListTake.
+// The depth of an item is derived from the indentation of its bullet relative
+// to the previous items.
+func ListMk(lines []string) ElementImpl {
+	res := ListElement{Indent: listIndent(lines[0])}
+	indents := []int{}
+	for _, line := range lines {
+		groups := listItemRe.Groups(line)
+		if groups == nil { // Continuation line.
+			last := &res.Items[len(res.Items)-1]
+			last.Lines = append(last.Lines, strings.TrimLeft(line, " \t"))
+			continue
+		}
+
+		indent := len(groups[1])
+		for len(indents) > 0 && indents[len(indents)-1] > indent {
+			indents = indents[:len(indents)-1]
+		}
+		if len(indents) == 0 || indents[len(indents)-1] < indent {
+			indents = append(indents, indent)
+		}
+		item := ListItem{
+			Lines:  []string{groups[3]},
+			Depth:  len(indents) - 1,
+			Bullet: groups[2],
+		}
+		if box := listCheckboxRe.Groups(groups[3]); box != nil {
+			checked := box[1] == "x" || box[1] == "X"
+			item.Checked = &checked
+			item.Partial = box[1] == "-"
+			item.Lines[0] = groups[3][len(box[0]):]
+		}
+		res.Items = append(res.Items, item)
+	}
+	res.Ordered = listOrdered(res.Items[0].Bullet)
+	return res
+}
+
+////////////
+// Fusing //
+////////////
+
+// fuseList reconstructs the lines of a list, using checkedMark for checked
+// checkboxes.
+// Nested items are indented to the content of their parent and ordered items
+// are renumbered, starting from the number of the first item of each sublist.
+func fuseList(l ListElement, checkedMark string) []string {
+	res := slice[string]{}
+	indents := []int{l.Indent}
+	numbers := []int{}
+	for _, item := range l.Items {
+		for len(indents) <= item.Depth {
+			indents = append(indents, indents[len(indents)-1]+2)
+		}
+		if len(numbers) > item.Depth {
+			numbers = numbers[:item.Depth+1]
+		}
+		for len(numbers) <= item.Depth {
+			numbers = append(numbers, 0)
+		}
+
+		bullet := item.Bullet
+		if listOrdered(bullet) {
+			if numbers[item.Depth] == 0 {
+				numbers[item.Depth], _ = strconv.Atoi(strings.TrimRight(bullet, ".)"))
+			} else {
+				numbers[item.Depth]++
+			}
+			bullet = strconv.Itoa(numbers[item.Depth]) + bullet[len(bullet)-1:]
+		} else {
+			numbers[item.Depth] = 0
+		}
+
+		indent := strings.Repeat(" ", indents[item.Depth])
+		content := strings.Repeat(" ", indents[item.Depth]+len(bullet)+1)
+		indents = append(indents[:item.Depth+1], len(content))
+
+		switch { // The checkbox does not affect the indentation.
+		case item.Checked == nil:
+		case item.Partial:
+			bullet += " [-]"
+		case *item.Checked:
+			bullet += " " + checkedMark
+		default:
+			bullet += " [ ]"
+		}
+
+		if item.TermSep != "" {
+			bullet += " " + item.Term + item.TermSep
+		}
+		for i, line := range item.Lines {
+			switch {
+			case i == 0 && item.TermSep != "":
+				res.Add(indent + bullet + line)
+			case i == 0 && line == "":
+				res.Add(indent + bullet)
+			case i == 0:
+				res.Add(indent + bullet + " " + line)
+			case line == "":
+				res.Add(line)
+			default:
+				res.Add(content + line)
+			}
+		}
+	}
+	return res
+}
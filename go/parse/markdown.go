@@ -0,0 +1,527 @@
+package parse
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+///////////////////
+// Text matching //
+///////////////////
+
+var markdownSectionRe = re(`^(?P<level>#{1,6})[ \t]+(?P<title>.+)$`)
+var markdownFencePfx = str("```")
+var markdownFrontMatterDelimiter = "---"
+var markdownIndentPfx = str("    ")
+var markdownTabPfx = str("\t")
+var markdownQuotePfx = str(">")
+var markdownSpacePfx = str(" ")
+var markdownBeginCommentPfx = str("<!--")
+var markdownEndComment = "-->"
+var markdownTableSeparatorRe = re(`^[ \t]*\|?(?:[ \t]*:?-+:?[ \t]*\|)*[ \t]*:?-+:?[ \t]*\|?[ \t]*$`)
+var markdownYAMLKeyRe = re(`^([^\s#:][^:]*):(?:[ \t]+(.*))?$`)
+
+////////////////
+// Primitives //
+////////////////
+
+// ParseMarkdownFence parses the language and noweb parameters of an opening
+// code fence.
+func ParseMarkdownFence(line string) (string, Parameters) {
+	line = markdownFencePfx.StripLeftOf(line)
+	line = spaces.Trim(line)
+	pos := spaces.First(line)
+	if pos == -1 {
+		return line, Parameters{}
+	}
+	return line[:pos], ParseNowebArguments(line[pos:])
+}
+
+// MarkdownFrontMatter parses the YAML front matter at the start of a Markdown
+// document into one MetadataElement per top-level key.
+// Simple `key: value` pairs are stored in Data, whereas nested YAML is stored
+// verbatim in RawValue.
+// Lines that precede the first key are kept in a nameless MetadataElement,
+// which is also made for an empty front matter, to keep its delimiters.
+func MarkdownFrontMatter(lines []string) (Elements, []string, error) {
+	if len(lines) == 0 || lines[0] != markdownFrontMatterDelimiter {
+		return Elements{}, lines, nil
+	}
+	end := -1
+	for i, line := range lines[1:] {
+		if line == markdownFrontMatterDelimiter {
+			end = i + 1
+			break
+		}
+	}
+	if end == -1 {
+		return Elements{}, lines, nil // Not front matter, probably a horizontal rule.
+	}
+
+	res := Elements{}
+	var current *MetadataElement
+	start := 0 // Line number of the current element.
+	flush := func(last int) {
+		if current != nil {
+			res = append(res, Element{ElementImpl: *current, Span: Span{StartLine: start, EndLine: last}})
+		}
+	}
+	for i, line := range lines[1:end] {
+		number := i + 2
+		groups := markdownYAMLKeyRe.Groups(line)
+		if groups == nil {
+			if current == nil {
+				current, start = &MetadataElement{Scope: ScopeDocument}, number
+			}
+			current.RawValue = append(current.RawValue, line)
+			continue
+		}
+		flush(number - 1)
+		current, start = &MetadataElement{Name: groups[1], Data: Parameters{}, Scope: ScopeDocument}, number
+		if groups[2] != "" {
+			current.Data.Add("", Values{groups[2]})
+		}
+	}
+	flush(end)
+	if end == 1 {
+		res = append(res, Element{ElementImpl: MetadataElement{Scope: ScopeDocument}, Span: Span{StartLine: 1, EndLine: 2}})
+	}
+	return res, lines[end+1:], nil
+}
+
+// markdownTableRow returns true if the line can be a row of a table.
+func markdownTableRow(line string) bool {
+	return strings.ContainsRune(line, '|') && !spaces.Intersects(line)
+}
+
+// splitMarkdownRow splits a table row into its unescaped cells.
+func splitMarkdownRow(line string) []string {
+	line = spaces.Trim(line)
+	line = str("|").StripLeftOf(line)
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	res := []string{}
+	cell := strings.Builder{}
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			res = append(res, spaces.Trim(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(res, spaces.Trim(cell.String()))
+}
+
+// markdownAlign returns the alignment described by a cell of a separator row.
+func markdownAlign(cell string) string {
+	left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+	switch {
+	case left && right:
+		return "center"
+	case left:
+		return "left"
+	case right:
+		return "right"
+	}
+	return ""
+}
+
+////////////
+// Takers //
+////////////
+
+// markdownTableTake takes a header row, a separator row and the body rows of a
+// table, which end at the first blank line or at the start of another element.
+func markdownTableTake(lines []string) int {
+	if len(lines) < 2 || !markdownTableRow(lines[0]) || !markdownTableSeparatorRe.Match(lines[1]) {
+		return 0
+	}
+	return 2 + GreedyTake(Nor(spaces.Intersects, markdownSectionRe.Match, markdownFencePfx.IsPrefix))(lines[2:])
+}
+
+// markdownIndented returns true if the line is indented enough to be verbatim.
+func markdownIndented(line string) bool {
+	return !spaces.Intersects(line) && (markdownIndentPfx.IsPrefix(line) || markdownTabPfx.IsPrefix(line))
+}
+
+// markdownExampleTake takes indented lines, blank lines included as long as
+// they are not the first or the last.
+func markdownExampleTake(lines []string) int {
+	if len(lines) == 0 || !markdownIndented(lines[0]) {
+		return 0
+	}
+	return TrailingTake(spaces.Intersects, markdownIndented)(lines)
+}
+
+// markdownCommentTake takes a HTML comment, which can begin and end on the same
+// line.
+func markdownCommentTake(lines []string) int {
+	if len(lines) == 0 || !markdownBeginCommentPfx.IsPrefix(lines[0]) {
+		return 0
+	}
+	if strings.Contains(markdownBeginCommentPfx.StripLeftOf(lines[0]), markdownEndComment) {
+		return 1
+	}
+	endsComment := func(line string) bool { return strings.Contains(line, markdownEndComment) }
+	return BetweenTake(markdownBeginCommentPfx.IsPrefix, endsComment)(lines)
+}
+
+// markdownBreakTake takes something when the lines start an element that
+// interrupts prose.
+func markdownBreakTake(lines []string) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	if markdownSectionRe.Match(lines[0]) || markdownFencePfx.IsPrefix(lines[0]) ||
+		listItemRe.Match(lines[0]) || markdownFootnoteRe.Match(lines[0]) ||
+		markdownBeginCommentPfx.IsPrefix(lines[0]) || markdownQuotePfx.IsPrefix(lines[0]) {
+		return 1
+	}
+	if spaces.Intersects(lines[0]) && len(lines) > 1 && markdownIndented(lines[1]) {
+		return 1 // Verbatim content cannot interrupt a paragraph, but can follow it.
+	}
+	return markdownTableTake(lines)
+}
+
+////////////
+// Makers //
+////////////
+
+// MarkdownTableMk makes a table element from Markdown lines.
+// Rows are padded with empty cells so that they all have the same length.
+func MarkdownTableMk(lines []string) ElementImpl {
+	res := TableElement{}
+	for i, line := range lines {
+		if i == 1 {
+			res.Align = Map(markdownAlign, splitMarkdownRow(line))
+		} else {
+			res.Rows = append(res.Rows, splitMarkdownRow(line))
+		}
+	}
+
+	width := len(res.Align)
+	for _, row := range res.Rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for len(res.Align) < width {
+		res.Align = append(res.Align, "")
+	}
+	for i := range res.Rows {
+		for len(res.Rows[i]) < width {
+			res.Rows[i] = append(res.Rows[i], "")
+		}
+	}
+	return res
+}
+
+// MarkdownIndentBk strips the indentation of a verbatim line.
+func MarkdownIndentBk(line string) string {
+	if markdownTabPfx.IsPrefix(line) {
+		return markdownTabPfx.StripLeftOf(line)
+	}
+	if spaces.Intersects(line) {
+		return ""
+	}
+	return markdownIndentPfx.StripLeftOf(line)
+}
+
+// MarkdownExampleMk makes an example element from indented Markdown lines
+// stripped of their indentation.
+func MarkdownExampleMk(lines []string) ElementImpl {
+	return ExampleElement{Raw: lines, Style: "indented"}
+}
+
+// MarkdownQuoteBk strips one quote marker, leaving the space following it to
+// MarkdownQuoteMk.
+func MarkdownQuoteBk(line string) string {
+	return markdownQuotePfx.StripLeftOf(line)
+}
+
+// MarkdownQuoteMk makes a quote element from Markdown lines stripped of one
+// quote marker.
+// Markers shared by all lines are stripped as well, increasing the level, the
+// markers of the first line being kept with their spacing.
+func MarkdownQuoteMk(lines []string) ElementImpl {
+	res := QuoteElement{Raw: lines, Level: 1, Marker: string(markdownQuotePfx)}
+	for {
+		nested := Map(markdownSpacePfx.StripLeftOf, res.Raw)
+		if GreedyTake(markdownQuotePfx.IsPrefix)(nested) != len(nested) {
+			break
+		}
+		res.Marker += res.Raw[0][:len(res.Raw[0])-len(nested[0])] + string(markdownQuotePfx)
+		res.Raw = Map(MarkdownQuoteBk, nested)
+		res.Level++
+	}
+	res.Raw = Map(markdownSpacePfx.StripLeftOf, res.Raw)
+	return res
+}
+
+// MarkdownCommentMk makes a comment element from a Markdown HTML comment.
+func MarkdownCommentMk(lines []string) ElementImpl {
+	return CommentElement{Raw: lines, Style: "html"}
+}
+
+// MarkdownCommenter turns lines into a Markdown HTML comment.
+// The end of comment marker is broken up in the lines, lest it ends the comment
+// early.
+func MarkdownCommenter(lines []string) ElementImpl {
+	res := CommentElement{Raw: slc(string(markdownBeginCommentPfx)), Style: "html"}
+	for _, line := range lines {
+		res.Raw = append(res.Raw, strings.ReplaceAll(line, markdownEndComment, "-- >"))
+	}
+	res.Raw = append(res.Raw, markdownEndComment)
+	return res
+}
+
+// MarkdownCodeMk makes a code element from Markdown lines.
+func MarkdownCodeMk(lines []string) ElementImpl {
+	lang, params := ParseMarkdownFence(lines[0])
+	return CodeElement{
+		Raw:    lines[1 : len(lines)-1],
+		Lang:   lang,
+		Params: params,
+	}
+}
+
+///////////////////////////////////
+// High-level parsing and fusing //
+///////////////////////////////////
+
+// MarkdownRules is a sequence of rules able to parse a Markdown file.
+var MarkdownRules = Rules{
+	Rule{ // Section, hierarchical delimiter of the document.
+		Name: "section",
+		Take: FirstTake(markdownSectionRe.Match),
+		Bake: NoBk,
+		Make: ReSectionMake(markdownSectionRe),
+	},
+	Rule{ // Code, content meant for machine consumption.
+		Name:      "code",
+		ErrorTake: BetweenErrorTake(markdownFencePfx.IsPrefix, markdownFencePfx.IsPrefix),
+		Bake:      NoBk,
+		Make:      MarkdownCodeMk,
+	},
+	Rule{ // Quote, content from another source.
+		Name: "quote",
+		Take: GreedyTake(markdownQuotePfx.IsPrefix),
+		Bake: MarkdownQuoteBk,
+		Make: MarkdownQuoteMk,
+	},
+	Rule{ // Comment, content that is not rendered.
+		Name: "comment",
+		Take: markdownCommentTake,
+		Bake: NoBk,
+		Make: MarkdownCommentMk,
+	},
+	Rule{ // Example, verbatim content that is never tangled.
+		Name: "example",
+		Take: markdownExampleTake,
+		Bake: MarkdownIndentBk,
+		Make: MarkdownExampleMk,
+	},
+	Rule{ // List, whose items can be nested.
+		Name: "list",
+		Take: ListTake(markdownSectionRe.Match),
+		Bake: NoBk,
+		Make: ListMk,
+	},
+	Rule{ // Footnote, referenced from elsewhere in the document.
+		Name: "footnote",
+		Take: FootnoteTake(markdownFootnoteRe.Match, Nor(markdownSectionRe.Match, markdownFencePfx.IsPrefix)),
+		Bake: NoBk,
+		Make: ReFootnoteMake(markdownFootnoteRe),
+	},
+	Rule{ // Table, with a header and aligned columns.
+		Name: "table",
+		Take: markdownTableTake,
+		Bake: NoBk,
+		Make: MarkdownTableMk,
+	},
+	SpaceRule, // Whitespace, content that can typically be ignored.
+	Rule{ // Prose, content meant for human consumption.
+		Name: "prose",
+		Take: UntilTake(spaces.Intersects, markdownBreakTake),
+		Bake: NoBk,
+		Make: ProseMk,
+	},
+}
+
+// fuseMarkdownTable renders a table, padding the cells so that columns line
+// up.
+func fuseMarkdownTable(t TableElement) []string {
+	escape := func(cell string) string { return strings.ReplaceAll(cell, "|", `\|`) }
+	widths := make([]int, len(t.Align))
+	for i := range widths {
+		widths[i] = 3 // Minimal width of a separator.
+	}
+	for _, row := range t.Rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(escape(cell)); i < len(widths) && n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	pad := func(cell string, i int) string {
+		missing := widths[i] - utf8.RuneCountInString(cell)
+		switch t.Align[i] {
+		case "right":
+			return strings.Repeat(" ", missing) + cell
+		case "center":
+			return strings.Repeat(" ", missing/2) + cell + strings.Repeat(" ", missing-missing/2)
+		}
+		return cell + strings.Repeat(" ", missing)
+	}
+	row := func(cells []string) string {
+		padded := make([]string, len(widths))
+		for i := range widths {
+			if i < len(cells) {
+				padded[i] = pad(escape(cells[i]), i)
+			} else {
+				padded[i] = pad("", i)
+			}
+		}
+		return "| " + strings.Join(padded, " | ") + " |"
+	}
+
+	separator := make([]string, len(widths))
+	for i, width := range widths {
+		dashes := strings.Repeat("-", width)
+		switch t.Align[i] {
+		case "left":
+			separator[i] = ":" + dashes[1:]
+		case "center":
+			separator[i] = ":" + dashes[2:] + ":"
+		case "right":
+			separator[i] = dashes[1:] + ":"
+		default:
+			separator[i] = dashes
+		}
+	}
+
+	res := slice[string]{}
+	for i, cells := range t.Rows {
+		res.Add(row(cells))
+		if i == 0 {
+			res.Add("| " + strings.Join(separator, " | ") + " |")
+		}
+	}
+	return res
+}
+
+// MarkdownStreamFuser can reconstruct the lines of a Markdown document from parsed
+// elements.
+// Metadata is only valid at the start of the document, where it is fused into
+// YAML front matter.
+func MarkdownStreamFuser(matter Elements, emit Emitter) error {
+	front := slc(matter...).IndexOf(Not(Element.IsMetadata))
+	if front == -1 {
+		front = len(matter)
+	}
+	if front > 0 {
+		emit(markdownFrontMatterDelimiter)
+		for _, part := range matter[:front] {
+			meta := part.ElementImpl.(MetadataElement)
+			if meta.Name != "" {
+				key := meta.Name + ":"
+				if !meta.Data.Empty() {
+					key += " " + meta.Data.FuseVerbatim()
+				}
+				emit(key)
+			}
+			emit(meta.RawValue...)
+		}
+		emit(markdownFrontMatterDelimiter)
+	}
+
+	for _, part := range matter[front:] {
+		switch p := part.ElementImpl.(type) {
+		case CodeElement:
+			begin := string(markdownFencePfx) + p.Lang
+			if len(p.Params) > 0 {
+				begin += " " + p.Params.FuseToNoweb()
+			}
+			emit(begin)
+			emit(p.Raw...)
+			emit(string(markdownFencePfx))
+
+		case ProseElement:
+			emit(p.Raw...)
+
+		case SectionElement:
+			emit(strings.Repeat("#", p.Level) + " " + p.Title)
+
+		case SpaceElement:
+			emit(p.Raw...)
+
+		case ExampleElement:
+			for _, line := range p.Raw {
+				if line == "" {
+					emit(line)
+				} else {
+					emit(string(markdownIndentPfx) + line)
+				}
+			}
+
+		case QuoteElement:
+			marker := p.Marker
+			if strings.Count(marker, string(markdownQuotePfx)) != p.Level {
+				marker = strings.Repeat(string(markdownQuotePfx), p.Level)
+			}
+			for _, line := range p.Raw {
+				if line == "" || markdownQuotePfx.IsPrefix(line) {
+					emit(marker + line)
+				} else {
+					emit(marker + " " + line)
+				}
+			}
+
+		case CommentElement:
+			emit(p.Raw...)
+
+		case FootnoteElement:
+			emit(fuseFootnote("[^"+p.Label+"]:", p)...)
+
+		case ListElement:
+			emit(fuseList(p, "[x]")...)
+
+		case TableElement:
+			emit(fuseMarkdownTable(p)...)
+
+		default:
+			return fuseError("markdown", part)
+		}
+	}
+	return nil
+}
+
+// MarkdownFuser is like MarkdownStreamFuser, but returns the lines.
+func MarkdownFuser(matter Elements) ([]string, error) {
+	return CollectFuse(MarkdownStreamFuser, matter)
+}
+
+// MarkdownLang holds information needed to manipulate Markdown files.
+var MarkdownLang = Language{
+	Identifiers: []string{"markdown", "md"},
+	Extensions:  []string{".md", ".markdown"},
+	Parser:      MarkdownRules,
+	Fuse:        MarkdownFuser,
+	Stream:      MarkdownStreamFuser,
+	Comment:     MarkdownCommenter,
+	Header:      MarkdownFrontMatter,
+	Markers:     Or(markdownSectionRe.Match, markdownFencePfx.IsPrefix),
+}
+
+// ParseMarkdown parses the lines of a Markdown document, including its front
+// matter.
+func ParseMarkdown(lines []string) (Elements, error) {
+	return MarkdownLang.Parse(lines)
+}
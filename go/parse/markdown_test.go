@@ -0,0 +1,73 @@
+package parse_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+	"github.com/mooss/litlib/parse/parsetest"
+)
+
+// frontMatter is a Markdown document whose front matter has values that would
+// be quoted as noweb arguments.
+var frontMatter = []string{
+	"---",
+	"title: My first post",
+	"date: 2024-01-02 10:00",
+	"tags:",
+	"  - go",
+	"---",
+	"",
+	"Text.",
+}
+
+func TestMarkdownFrontMatterRoundTrip(t *testing.T) {
+	parsetest.AssertRoundTrip(t, parse.MarkdownLang, frontMatter)
+}
+
+func TestMarkdownFrontMatterMetadata(t *testing.T) {
+	matter, err := parse.MarkdownLang.Parse(frontMatter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := parse.Parameters{
+		{Key: "title", Values: parse.Values{"My first post"}},
+		{Key: "date", Values: parse.Values{"2024-01-02 10:00"}},
+		{Key: "tags", Values: parse.Values{"  - go"}},
+	}
+	if metadata := matter.Metadata(); !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("metadata is %v, expected %v", metadata, expected)
+	}
+}
+
+func TestMarkdownEmptyFrontMatterRoundTrip(t *testing.T) {
+	parsetest.AssertRoundTrip(t, parse.MarkdownLang, []string{"---", "---", "", "Text."})
+	parsetest.AssertRoundTrip(t, parse.MarkdownLang, []string{"---", "---"})
+
+	matter, err := parse.MarkdownLang.Parse([]string{"---", "---", "Text."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata := matter.Metadata(); len(metadata) != 0 {
+		t.Errorf("empty front matter has metadata %v", metadata)
+	}
+}
+
+func TestMarkdownNestedQuoteMarkers(t *testing.T) {
+	for _, input := range [][]string{
+		{"> > a", "> > b"},
+		{">> a", ">>", ">> b"},
+		{"> >> a"},
+		{"> a", ">", "> b"},
+	} {
+		parsetest.AssertRoundTrip(t, parse.MarkdownLang, input)
+	}
+
+	matter, err := parse.MarkdownLang.Parse([]string{"> > a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote := matter[0].ElementImpl.(parse.QuoteElement); quote.Level != 2 || !reflect.DeepEqual(quote.Raw, []string{"a"}) {
+		t.Errorf("nested quote parsed into %v", quote.Repr())
+	}
+}
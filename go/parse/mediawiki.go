@@ -0,0 +1,169 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+)
+
+///////////////////
+// Text matching //
+///////////////////
+
+var mediaWikiSectionRe = re(`^(=+)([ \t]*)(.*?[^ \t])([ \t]*)(=+)[ \t]*$`)
+var mediaWikiBeginCodeRe = re(`^<syntaxhighlight((?:[ \t]+[^>]*)?)>[ \t]*$`)
+var mediaWikiAttributeRe = re(`([\w-]+)(?:[ \t]*=[ \t]*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+var mediaWikiBeginCodePfx = str("<syntaxhighlight")
+var mediaWikiEndCodePfx = str("</syntaxhighlight>")
+
+// mediaWikiSection returns true if the line is a heading, with the same number
+// of equal signs on both sides.
+func mediaWikiSection(line string) bool {
+	groups := mediaWikiSectionRe.Groups(line)
+	return groups != nil && groups[1] == groups[5]
+}
+
+// mediaWikiHeading returns true if the line is a heading with a title, unlike
+// the lines of equal signs underlining titles in other languages.
+func mediaWikiHeading(line string) bool {
+	return mediaWikiSection(line) && strings.Trim(line, "= \t") != ""
+}
+
+////////////
+// Makers //
+////////////
+
+// MediaWikiSectionMk makes a section element from a MediaWiki heading, its
+// level being the number of equal signs.
+// The spacing between the equal signs and the title is stored in the spacing
+// parameter, as the number of spaces on the left and right sides.
+func MediaWikiSectionMk(lines []string) ElementImpl {
+	groups := mediaWikiSectionRe.Groups(lines[0])
+	return SectionElement{
+		Title: groups[3],
+		Level: len(groups[1]),
+		Params: Parameters{{
+			Key:    "spacing",
+			Values: Values{strconv.Itoa(len(groups[2])), strconv.Itoa(len(groups[4]))},
+		}},
+	}
+}
+
+// MediaWikiCodeMk makes a code element from a syntaxhighlight tag.
+// The lang attribute is the language of the code, the other attributes are
+// stored in the parameters.
+// Attribute values can be double-quoted, single-quoted or unquoted.
+func MediaWikiCodeMk(lines []string) ElementImpl {
+	res := CodeElement{Raw: lines[1 : len(lines)-1], Params: Parameters{}}
+	groups := mediaWikiBeginCodeRe.Groups(lines[0])
+	for _, attr := range mediaWikiAttributeRe.FindAllStringSubmatch(groups[1], -1) {
+		value := attr[2] + attr[3] + attr[4] // At most one of them is matched.
+		switch {
+		case attr[1] == "lang":
+			res.Lang = value
+		case strings.Contains(attr[0], "="):
+			res.Params.Add(attr[1], Values{value})
+		default:
+			res.Params.Add(attr[1], nil)
+		}
+	}
+	return res
+}
+
+///////////////////////////////////
+// High-level parsing and fusing //
+///////////////////////////////////
+
+// MediaWikiRules is a sequence of rules able to parse a MediaWiki file.
+var MediaWikiRules = Rules{
+	Rule{ // Section, hierarchical delimiter of the document.
+		Name: "section",
+		Take: FirstTake(mediaWikiSection),
+		Bake: NoBk,
+		Make: MediaWikiSectionMk,
+	},
+	Rule{ // Code, content meant for machine consumption.
+		// Tags followed by code on their line are left to prose, to be kept as is.
+		Name: "code",
+		Take: BetweenTake(mediaWikiBeginCodeRe.Match, mediaWikiEndCodePfx.IsPrefix),
+		Bake: NoBk,
+		Make: MediaWikiCodeMk,
+	},
+	SpaceRule, // Whitespace, content that can typically be ignored.
+	Rule{ // Prose, content meant for human consumption.
+		Name: "prose",
+		Take: TrailingTake(spaces.Intersects, Nor(mediaWikiSection, mediaWikiBeginCodeRe.Match)),
+		Bake: NoBk,
+		Make: ProseMk,
+	},
+}
+
+// mediaWikiSpacing returns the spacing around the title of a section, one
+// space on each side by default.
+func mediaWikiSpacing(s SectionElement) (left, right string) {
+	left, right = " ", " "
+	vp := s.Params.Get("spacing")
+	if vp == nil || len(*vp) != 2 {
+		return
+	}
+	if n, err := strconv.Atoi((*vp)[0]); err == nil {
+		left = strings.Repeat(" ", n)
+	}
+	if n, err := strconv.Atoi((*vp)[1]); err == nil {
+		right = strings.Repeat(" ", n)
+	}
+	return
+}
+
+// mediaWikiQuote quotes an attribute value, with single quotes when it holds
+// double quotes.
+func mediaWikiQuote(value string) string {
+	if strings.Contains(value, `"`) {
+		return "'" + value + "'"
+	}
+	return `"` + value + `"`
+}
+
+// MediaWikiStreamFuser can reconstruct the lines of a MediaWiki document from parsed
+// elements.
+var MediaWikiStreamFuser = StreamFuserFromTable("mediawiki", mediaWikiFuseTable())
+
+// mediaWikiFuseTable returns the functions fusing MediaWiki elements.
+func mediaWikiFuseTable() FuseTable {
+	res := FuseTable{}
+	FuseOn(res, func(p CodeElement) []string {
+		begin := string(mediaWikiBeginCodePfx)
+		if p.Lang != "" {
+			begin += " lang=" + mediaWikiQuote(p.Lang)
+		}
+		for _, param := range p.Params {
+			begin += " " + param.Key
+			if len(param.Values) > 0 {
+				begin += "=" + mediaWikiQuote(strings.Join(param.Values, " "))
+			}
+		}
+		return *pslc(begin + ">").Add(p.Raw...).Add(string(mediaWikiEndCodePfx))
+	})
+	FuseOn(res, func(p ProseElement) []string { return p.Raw })
+	FuseOn(res, func(p SectionElement) []string {
+		equals := strings.Repeat("=", p.Level)
+		left, right := mediaWikiSpacing(p)
+		return slc(equals + left + p.Title + right + equals)
+	})
+	FuseOn(res, func(p SpaceElement) []string { return p.Raw })
+	return res
+}
+
+// MediaWikiFuser is like MediaWikiStreamFuser, but returns the lines.
+func MediaWikiFuser(matter Elements) ([]string, error) {
+	return CollectFuse(MediaWikiStreamFuser, matter)
+}
+
+// MediaWikiLang holds information needed to manipulate MediaWiki files.
+var MediaWikiLang = Language{
+	Identifiers: []string{"mediawiki", "wiki"},
+	Extensions:  []string{".wiki", ".mediawiki"},
+	Parser:      MediaWikiRules,
+	Fuse:        MediaWikiFuser,
+	Stream:      MediaWikiStreamFuser,
+	Markers:     Or(mediaWikiHeading, mediaWikiBeginCodeRe.Match),
+}
@@ -0,0 +1,55 @@
+package parse_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+	"github.com/mooss/litlib/parse/parsetest"
+)
+
+func TestMediaWikiCodeAttributes(t *testing.T) {
+	for begin, fused := range map[string]string{
+		`<syntaxhighlight lang="python">`:             `<syntaxhighlight lang="python">`,
+		`<syntaxhighlight lang=python line>`:          `<syntaxhighlight lang="python" line>`,
+		`<syntaxhighlight lang='python' start=3>`:     `<syntaxhighlight lang="python" start="3">`,
+		`<syntaxhighlight lang = "go" title='a "b"'>`: `<syntaxhighlight lang="go" title='a "b"'>`,
+	} {
+		matter, err := parse.MediaWikiLang.Parse([]string{begin, "print(1)", "</syntaxhighlight>"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, err := parse.MediaWikiLang.Fuse(matter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines[0] != fused {
+			t.Errorf("`%s` fused into `%s`, expected `%s`", begin, lines[0], fused)
+		}
+	}
+}
+
+func TestMediaWikiInlineCodeIsKept(t *testing.T) {
+	parsetest.AssertRoundTrip(t, parse.MediaWikiLang, []string{`<syntaxhighlight lang="go">x := 1`, "y := 2", "</syntaxhighlight>"})
+}
+
+func TestMediaWikiSectionLevels(t *testing.T) {
+	input := []string{"= Page =", "== Section ==", "===Subsection==="}
+	parsetest.AssertRoundTrip(t, parse.MediaWikiLang, input)
+
+	matter, err := parse.MediaWikiLang.Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels := []int{}
+	for _, part := range matter {
+		section, _ := part.AsSection()
+		levels = append(levels, section.Level)
+	}
+	if !reflect.DeepEqual(levels, []int{1, 2, 3}) {
+		t.Errorf("sections parsed into levels %v", levels)
+	}
+	if _, err := parse.BuildTree(matter); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,41 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMonoTake(t *testing.T) {
+	take := MonoTake(orgPropertyPfx.IsPrefix)
+	if taken := take([]string{"#+title: A", "#+author: B"}); taken != 1 {
+		t.Errorf("took %d lines of consecutive metadata, expected 1", taken)
+	}
+	if taken := take([]string{"Prose."}); taken != 0 {
+		t.Errorf("took %d lines of prose", taken)
+	}
+}
+
+func TestMonoMake(t *testing.T) {
+	made := MonoMake(func(line string) ElementImpl { return ProseElement{Raw: []string{line + "!"}} })([]string{"first", "second"})
+	if expected := (ProseElement{Raw: []string{"first!"}}); !reflect.DeepEqual(made, expected) {
+		t.Errorf("made %v, expected %v", made, expected)
+	}
+}
+
+func TestOrgMetadataRule(t *testing.T) {
+	matter, err := OrgRules.Parse([]string{"#+title: Parsing: a story", "#+property: header-args :tangle no"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matter) != 2 {
+		t.Fatalf("two metadata lines parsed into %d elements", len(matter))
+	}
+	expected := MetadataElement{
+		Name:  "property",
+		Data:  Parameters{{"", Values{"header-args :tangle no"}}},
+		Scope: ScopeDocument,
+	}
+	if !reflect.DeepEqual(matter[1].ElementImpl, expected) {
+		t.Errorf("property parsed into %v, expected %v", matter[1].Repr(), expected.Repr())
+	}
+}
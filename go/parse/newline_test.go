@@ -0,0 +1,45 @@
+package parse_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+)
+
+func TestFinalNewlineRoundTrip(t *testing.T) {
+	documents := map[string]string{
+		"org":      "* Title\nText.\n#+begin_src sh\necho\n#+end_src",
+		"markdown": "# Title\n\nText.\n\n```sh\necho\n```",
+	}
+	for _, lang := range []parse.Language{parse.OrgLang, parse.MarkdownLang} {
+		document := documents[lang.Identifiers[0]]
+		for _, input := range []string{document, document + "\n", document + "\n\n"} {
+			parsed, err := lang.Parse(strings.Split(input, "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFusedBytes(t, lang, parsed, input)
+
+			read, err := lang.Parser.ParseReader(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFusedBytes(t, lang, read, input)
+		}
+	}
+}
+
+// assertFusedBytes fails the test when fusing matter with lang does not give
+// the bytes of expected.
+func assertFusedBytes(t *testing.T, lang parse.Language, matter parse.Elements, expected string) {
+	t.Helper()
+	fused := bytes.Buffer{}
+	if err := lang.FuseWriter(matter, &fused); err != nil {
+		t.Fatal(err)
+	}
+	if fused.String() != expected {
+		t.Errorf("%s document %q fused into %q", lang.Identifiers[0], expected, fused.String())
+	}
+}
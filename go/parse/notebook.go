@@ -0,0 +1,290 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Jupyter notebooks are JSON documents rather than line-based ones, so they are
+// not described by a Language but parsed and fused directly.
+//
+// The notebook-level information is stored in a MetadataElement named
+// notebook, placed at the start of the elements.
+// Code cells keep their id, execution count, metadata and outputs in the
+// parameters of the CodeElement, as compact JSON.
+// Markdown cells become ProseElement and raw cells become a BlockElement of
+// type raw; their id and metadata are kept as noweb arguments in the
+// notebookAttributes affiliated parameter, the way Org attributes are written.
+// Cells without an id are given one when the format requires it.
+
+const notebookName = "notebook"
+const notebookAttributes = "ATTR_NOTEBOOK"
+
+type notebook struct {
+	Cells         []notebookCell  `json:"cells"`
+	Metadata      json.RawMessage `json:"metadata"`
+	Nbformat      int             `json:"nbformat"`
+	NbformatMinor int             `json:"nbformat_minor"`
+}
+
+type notebookCell struct {
+	CellType       string          `json:"cell_type"`
+	ExecutionCount json.RawMessage `json:"execution_count,omitempty"`
+	ID             string          `json:"id,omitempty"`
+	Metadata       json.RawMessage `json:"metadata"`
+	Outputs        json.RawMessage `json:"outputs,omitempty"`
+	Source         notebookSource  `json:"source"`
+}
+
+// notebookSource is the source of a cell, as lines without line terminators.
+// It can be decoded from a single string or from an array of lines.
+type notebookSource []string
+
+func (ns *notebookSource) UnmarshalJSON(data []byte) error {
+	var joined string
+	if err := json.Unmarshal(data, &joined); err != nil {
+		var lines []string
+		if err := json.Unmarshal(data, &lines); err != nil {
+			return fmt.Errorf("cell source is neither a string nor an array of strings")
+		}
+		joined = strings.Join(lines, "")
+	}
+	if joined == "" {
+		*ns = notebookSource{}
+	} else {
+		*ns = strings.Split(joined, "\n")
+	}
+	return nil
+}
+
+// MarshalJSON encodes the source as an array of lines, the canonical format.
+func (ns notebookSource) MarshalJSON() ([]byte, error) {
+	res := make([]string, 0, len(ns))
+	for i, line := range ns {
+		if i < len(ns)-1 {
+			line += "\n"
+		}
+		if line != "" {
+			res = append(res, line)
+		}
+	}
+	return json.Marshal(res)
+}
+
+// compact returns the compact representation of a JSON value, defaulting to
+// fallback when it is absent.
+func compact(raw json.RawMessage, fallback string) (string, error) {
+	if len(raw) == 0 {
+		return fallback, nil
+	}
+	buf := bytes.Buffer{}
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// notebookLang extracts the language of the notebook from its metadata.
+func notebookLang(metadata json.RawMessage) string {
+	var meta struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	}
+	if json.Unmarshal(metadata, &meta) != nil {
+		return ""
+	}
+	if meta.Kernelspec.Language != "" {
+		return meta.Kernelspec.Language
+	}
+	return meta.LanguageInfo.Name
+}
+
+// ParseNotebook parses a Jupyter notebook into elements, in cell order.
+func ParseNotebook(r io.Reader) (Elements, error) {
+	var nb notebook
+	if err := json.NewDecoder(r).Decode(&nb); err != nil {
+		return nil, fmt.Errorf("could not decode notebook: %w", err)
+	}
+
+	metadata, err := compact(nb.Metadata, "{}")
+	if err != nil {
+		return nil, err
+	}
+	res := Elements{{ElementImpl: MetadataElement{
+		Name: notebookName,
+		Data: Parameters{
+			{"metadata", Values{metadata}},
+			{"nbformat", Values{strconv.Itoa(nb.Nbformat)}},
+			{"nbformat_minor", Values{strconv.Itoa(nb.NbformatMinor)}},
+		},
+		Scope: ScopeDocument,
+	}}}
+
+	lang := notebookLang(nb.Metadata)
+	for i, cell := range nb.Cells {
+		switch cell.CellType {
+		case "markdown", "raw":
+			metadata, err := compact(cell.Metadata, "{}")
+			if err != nil {
+				return nil, err
+			}
+			attrs := Parameters{}
+			if cell.ID != "" {
+				attrs.Add("id", Values{cell.ID})
+			}
+			attrs.Add("metadata", Values{metadata})
+			part := Element{
+				ElementImpl: ProseElement{Raw: cell.Source},
+				Affiliated:  Parameters{{Key: notebookAttributes, Values: Values{attrs.FuseToNoweb()}}},
+			}
+			if cell.CellType == "raw" {
+				part.ElementImpl = BlockElement{Raw: cell.Source, Type: "raw"}
+			}
+			res = append(res, part)
+
+		case "code":
+			code := CodeElement{Raw: cell.Source, Lang: lang, Params: Parameters{}}
+			if cell.ID != "" {
+				code.Params.Add("id", Values{cell.ID})
+			}
+			for _, field := range []struct {
+				key      string
+				raw      json.RawMessage
+				fallback string
+			}{
+				{"execution_count", cell.ExecutionCount, "null"},
+				{"metadata", cell.Metadata, "{}"},
+				{"outputs", cell.Outputs, "[]"},
+			} {
+				value, err := compact(field.raw, field.fallback)
+				if err != nil {
+					return nil, err
+				}
+				code.Params.Add(field.key, Values{value})
+			}
+			res = append(res, Element{ElementImpl: code})
+
+		default:
+			return nil, fmt.Errorf("unknown type `%s` for cell %d", cell.CellType, i)
+		}
+	}
+	return res, nil
+}
+
+// notebookParam returns the first value of a parameter or fallback if there is
+// none.
+func notebookParam(ps Parameters, key, fallback string) string {
+	if vp := ps.Get(key); vp != nil && len(*vp) > 0 {
+		return (*vp)[0]
+	}
+	return fallback
+}
+
+// FuseNotebook serialises elements into a Jupyter notebook.
+// Sections become markdown cells and whitespace is dropped, since cells are
+// already separated.
+func FuseNotebook(matter Elements) ([]byte, error) {
+	nb := notebook{
+		Cells:         []notebookCell{},
+		Metadata:      json.RawMessage("{}"),
+		Nbformat:      4,
+		NbformatMinor: 5,
+	}
+	var err error
+	for _, part := range matter {
+		switch p := part.ElementImpl.(type) {
+		case CodeElement:
+			nb.Cells = append(nb.Cells, notebookCell{
+				CellType:       "code",
+				ExecutionCount: json.RawMessage(notebookParam(p.Params, "execution_count", "null")),
+				ID:             notebookParam(p.Params, "id", ""),
+				Metadata:       json.RawMessage(notebookParam(p.Params, "metadata", "{}")),
+				Outputs:        json.RawMessage(notebookParam(p.Params, "outputs", "[]")),
+				Source:         p.Raw,
+			})
+
+		case ProseElement:
+			nb.Cells = append(nb.Cells, notebookTextCell("markdown", part, p.Raw))
+
+		case SectionElement:
+			nb.Cells = append(nb.Cells, notebookTextCell("markdown", part, notebookSource{strings.Repeat("#", p.Level) + " " + p.Title}))
+
+		case BlockElement:
+			if p.Type != "raw" {
+				return nil, FuseError{Lang: "notebook", Type: p.Type + " blocks"}
+			}
+			nb.Cells = append(nb.Cells, notebookTextCell("raw", part, p.Raw))
+
+		case MetadataElement:
+			if p.Name != notebookName {
+				return nil, FuseError{Lang: "notebook", Type: p.Name + " metadata"}
+			}
+			data := p.Data
+			if value := orgMetadataValue(p); value != "" { // Verbatim, like when parsed from Org.
+				data = ParseNowebArguments(value)
+			}
+			nb.Metadata = json.RawMessage(notebookParam(data, "metadata", "{}"))
+			if nb.Nbformat, err = strconv.Atoi(notebookParam(data, "nbformat", "4")); err != nil {
+				return nil, err
+			}
+			if nb.NbformatMinor, err = strconv.Atoi(notebookParam(data, "nbformat_minor", "5")); err != nil {
+				return nil, err
+			}
+
+		case SpaceElement:
+
+		default:
+			return nil, fuseError("notebook", part)
+		}
+	}
+
+	if nb.Nbformat > 4 || nb.Nbformat == 4 && nb.NbformatMinor >= 5 {
+		notebookFillIDs(nb.Cells)
+	}
+	res, err := json.MarshalIndent(nb, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return append(res, '\n'), nil
+}
+
+// notebookTextCell makes a markdown or raw cell, with the id and metadata
+// affiliated to the element.
+func notebookTextCell(cellType string, part Element, source notebookSource) notebookCell {
+	attrs := Parameters{}
+	if vp := part.Affiliated.Get(notebookAttributes); vp != nil {
+		attrs = ParseNowebArguments(strings.Join(*vp, " "))
+	}
+	return notebookCell{
+		CellType: cellType,
+		ID:       notebookParam(attrs, "id", ""),
+		Metadata: json.RawMessage(notebookParam(attrs, "metadata", "{}")),
+		Source:   source,
+	}
+}
+
+// notebookFillIDs gives an id to the cells lacking one, distinct from the ids
+// of the other cells.
+func notebookFillIDs(cells []notebookCell) {
+	used := map[string]bool{}
+	for _, cell := range cells {
+		used[cell.ID] = true
+	}
+	next := 0
+	for i := range cells {
+		for cells[i].ID == "" {
+			if id := "cell-" + strconv.Itoa(next); !used[id] {
+				cells[i].ID, used[id] = id, true
+			}
+			next++
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package parse_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+)
+
+const cellsNotebook = `{
+ "cells": [
+  {"cell_type": "markdown", "id": "intro", "metadata": {"tags": ["a b"]}, "source": ["Some text,\n", "on two lines."]},
+  {"cell_type": "raw", "id": "raw-1", "metadata": {"format": "text/x-rst"}, "source": [".. note::"]},
+  {"cell_type": "code", "execution_count": 1, "id": "code-1", "metadata": {}, "outputs": [], "source": ["print(1)"]}
+ ],
+ "metadata": {"kernelspec": {"language": "python"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+
+// decodeJSON decodes JSON data into generic values, for comparisons.
+func decodeJSON(t *testing.T, data []byte) interface{} {
+	t.Helper()
+	var res interface{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestNotebookCellsRoundTrip(t *testing.T) {
+	matter, err := parse.ParseNotebook(strings.NewReader(cellsNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fused, err := parse.FuseNotebook(matter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := decodeJSON(t, []byte(cellsNotebook)); !reflect.DeepEqual(decodeJSON(t, fused), expected) {
+		t.Errorf("notebook fused into\n%s", fused)
+	}
+
+	// Through Org, where ids and metadata are affiliated attributes.
+	lines, err := parse.OrgLang.Fuse(matter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := parse.OrgLang.Parse(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fused, err = parse.FuseNotebook(parse.AttachAffiliated(reparsed)); err != nil {
+		t.Fatal(err)
+	}
+	cells := decodeJSON(t, fused).(map[string]interface{})["cells"].([]interface{})
+	for i, id := range []string{"intro", "raw-1", "code-1"} {
+		if cell := cells[i].(map[string]interface{}); cell["id"] != id {
+			t.Errorf("cell %d has id %v through Org, expected %s", i, cell["id"], id)
+		}
+	}
+}
+
+func TestNotebookFillsIDs(t *testing.T) {
+	matter, err := parse.MarkdownLang.Parse([]string{"# Title", "", "Text."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fused, err := parse.FuseNotebook(matter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[interface{}]bool{}
+	for _, cell := range decodeJSON(t, fused).(map[string]interface{})["cells"].([]interface{}) {
+		ids[cell.(map[string]interface{})["id"]] = true
+	}
+	if len(ids) != 2 || ids[nil] {
+		t.Errorf("cells were given ids %v", ids)
+	}
+}
@@ -1,21 +1,16 @@
 package parse
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 )
 
+// NextNowebKeyValues extracts the first key of noweb arguments and its values,
+// returning the rest of the arguments, which starts with the next key.
 func NextNowebKeyValues(data string) (key string, values []string, rest string) {
 	skimspace := func() int {
-		for i, r := range rest {
-			if r != ' ' {
-				return i
-			}
-		}
-		return -1
-	}
-
-	slicevals := func(s string) []string {
-		return strings.Fields(strings.TrimRight(s, " "))
+		return spaces.Skim(rest)
 	}
 
 	/////////////////////
@@ -27,7 +22,7 @@ func NextNowebKeyValues(data string) (key string, values []string, rest string)
 	}
 	rest = data[idx:]
 	if rest[0] == ':' {
-		end := strings.IndexByte(rest[1:], ' ') + 1
+		end := spaces.First(rest[1:]) + 1
 		if end == 0 {
 			return rest[1:], nil, "" // key without value.
 		}
@@ -37,26 +32,86 @@ func NextNowebKeyValues(data string) (key string, values []string, rest string)
 
 	////////////////////////
 	// Extract the values //
-	idx = skimspace()
-	if idx == -1 {
-		return key, nil, "" // key without value.
+	for {
+		idx = skimspace()
+		if idx == -1 {
+			return key, values, "" // Last value.
+		}
+		rest = rest[idx:]
+		if rest[0] == ':' {
+			return key, values, rest // Next key.
+		}
+		var value string
+		value, rest = nextNowebValue(rest)
+		values = append(values, value)
+	}
+}
+
+// nextNowebValue extracts the value at the start of data.
+// A value is either a word or a double-quoted string, in which backslashes
+// escape the next character.
+// A quote that is not terminated or not followed by a space is treated as part
+// of a word.
+func nextNowebValue(data string) (value, rest string) {
+	word := func() (string, string) {
+		end := spaces.First(data)
+		if end == -1 {
+			return data, ""
+		}
+		return data[:end], data[end:]
+	}
+	if data[0] != '"' {
+		return word()
+	}
+
+	acc := strings.Builder{}
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			if i+1 < len(data) {
+				i++
+			}
+			acc.WriteByte(data[i])
+		case '"':
+			if i+1 < len(data) && !spaces.HasRune(rune(data[i+1])) {
+				return word() // Not a quoted string, e.g. `"a",`.
+			}
+			return acc.String(), data[i+1:]
+		default:
+			acc.WriteByte(data[i])
+		}
 	}
-	rest = rest[idx:]
+	return word()
+}
 
-	end := strings.Index(rest, " :")
-	if end == -1 {
-		return key, slicevals(rest), "" // Last value.
+// fuseNowebValue quotes a value when it would otherwise not be parsed back by
+// nextNowebValue, e.g. when it is empty, has spaces or starts with a colon.
+// The value is parsed along with the fused arguments following it, since a
+// value starting with a quote could otherwise absorb them.
+func fuseNowebValue(value, following string) string {
+	if value != "" && spaces.First(value) == -1 && value[0] != ':' {
+		data := value
+		if following != "" {
+			data += " " + following
+		}
+		if parsed, _ := nextNowebValue(data); parsed == value {
+			return value
+		}
 	}
-	return key, slicevals(rest[:end]), rest[end+1:]
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return `"` + escaped + `"`
 }
 
-// ParseNowebArguments parses noweb arguments into an argument map.
+// ParseNowebArguments parses noweb arguments into parameters.
 // For example, ":exports none :include iostream vector :minipage" becomes:
-// map[string][]string {
-//     "exports": ["none"],
-//     "include": ["iostream", "vector"],
-//     "minipage": [],
+// Parameters {
+//     {"exports", ["none"]},
+//     {"include", ["iostream", "vector"]},
+//     {"minipage", []},
 // }
+// Positional values preceding the first key are stored under the empty key.
+// Keys are only delimited by whitespace, so that ":a:b" is the key "a:b".
+// This is the parser used for the arguments of every language.
 func ParseNowebArguments(source string) Parameters {
 	res := Parameters{}
 	var key string
@@ -70,3 +125,188 @@ func ParseNowebArguments(source string) Parameters {
 	}
 	return res
 }
+
+// nowebReferenceRe matches a line referencing a code block, capturing the
+// indentation and the name of the block.
+var nowebReferenceRe = re(`^([ \t]*)<<([^<>]+)>>[ \t]*$`)
+
+// nowebName returns the name of a code block, given by its name parameter or
+// else by the document, see AttachAffiliated.
+func nowebName(part Element, code CodeElement) string {
+	if name := code.Params.Get("name"); name != nil && len(*name) > 0 {
+		return strings.Join(*name, " ")
+	}
+	return part.Name
+}
+
+// nowebBlocks indexes the code of named blocks, concatenating the blocks sharing
+// a name in document order.
+func nowebBlocks(matter Elements) map[string][]string {
+	res := map[string][]string{}
+	for _, part := range matter {
+		code, ok := part.ElementImpl.(CodeElement)
+		if !ok {
+			continue
+		}
+		if name := nowebName(part, code); name != "" {
+			res[name] = append(res[name], code.Raw...)
+		}
+	}
+	return res
+}
+
+// ExpandNoweb substitutes the lines referencing a named code block, like
+// `<<name>>`, with the code of the block, indented like the reference.
+// References to unknown blocks are left untouched and cyclic references are
+// reported as errors.
+// The given elements are not modified.
+func ExpandNoweb(matter Elements) (Elements, error) {
+	blocks := nowebBlocks(matter)
+	expanded := map[string][]string{}
+
+	var expand func(raw []string, chain []string) ([]string, error)
+	expand = func(raw []string, chain []string) ([]string, error) {
+		res := []string{}
+		for _, line := range raw {
+			groups := nowebReferenceRe.Groups(line)
+			if groups == nil {
+				res = append(res, line)
+				continue
+			}
+			if _, ok := blocks[groups[2]]; !ok {
+				res = append(res, line)
+				continue
+			}
+
+			indent, name := groups[1], groups[2]
+			for i, link := range chain {
+				if link == name {
+					cycle := append(append([]string{}, chain[i:]...), name)
+					return nil, fmt.Errorf("cyclic noweb reference: %s", strings.Join(cycle, " -> "))
+				}
+			}
+			if _, ok := expanded[name]; !ok {
+				sub, err := expand(blocks[name], append(chain, name))
+				if err != nil {
+					return nil, err
+				}
+				expanded[name] = sub
+			}
+			for _, sub := range expanded[name] {
+				if sub == "" {
+					res = append(res, sub)
+				} else {
+					res = append(res, indent+sub)
+				}
+			}
+		}
+		return res, nil
+	}
+
+	res := make(Elements, len(matter))
+	for i, part := range matter {
+		res[i] = part
+		code, ok := part.ElementImpl.(CodeElement)
+		if !ok {
+			continue
+		}
+		chain := []string{}
+		if name := nowebName(part, code); name != "" {
+			chain = append(chain, name)
+		}
+		raw, err := expand(code.Raw, chain)
+		if err != nil {
+			return nil, err
+		}
+		code.Raw = raw
+		res[i].ElementImpl = code
+	}
+	return res, nil
+}
+
+// NowebGraph returns, for each named code block, the names of the blocks it
+// references, in order of first reference.
+// References to undefined blocks are included.
+func NowebGraph(matter Elements) map[string][]string {
+	res := map[string][]string{}
+	for name, raw := range nowebBlocks(matter) {
+		refs := []string{}
+		seen := map[string]bool{}
+		for _, line := range raw {
+			groups := nowebReferenceRe.Groups(line)
+			if groups != nil && !seen[groups[2]] {
+				refs = append(refs, groups[2])
+				seen[groups[2]] = true
+			}
+		}
+		res[name] = refs
+	}
+	return res
+}
+
+// TopoSort orders the nodes of a graph so that each node comes after the nodes
+// it references, which is a valid tangling order for a noweb graph.
+// Nodes without references are ordered by name, for determinism.
+// Cycles and references to undefined nodes are reported as errors, along with
+// the order of the defined nodes in the latter case.
+func TopoSort(graph map[string][]string) ([]string, error) {
+	names := make([]string, 0, len(graph))
+	for name := range graph {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	res := []string{}
+	undefined := []string{}
+	chain := []string{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, link := range chain {
+				if link == name {
+					cycle := append(append([]string{}, chain[i:]...), name)
+					return fmt.Errorf("cyclic noweb reference: %s", strings.Join(cycle, " -> "))
+				}
+			}
+		}
+
+		state[name] = visiting
+		chain = append(chain, name)
+		for _, ref := range graph[name] {
+			if _, defined := graph[ref]; !defined {
+				if state[ref] == unvisited {
+					undefined = append(undefined, ref)
+					state[ref] = visited
+				}
+				continue
+			}
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		chain = chain[:len(chain)-1]
+		state[name] = visited
+		res = append(res, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	if len(undefined) > 0 {
+		return res, fmt.Errorf("undefined noweb references: %s", strings.Join(undefined, ", "))
+	}
+	return res, nil
+}
@@ -0,0 +1,125 @@
+package parse_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+	"github.com/mooss/litlib/parse/parsetest"
+)
+
+func TestFuseToNowebQuoting(t *testing.T) {
+	for _, tc := range []struct {
+		params parse.Parameters
+		fused  string
+	}{
+		{parse.Parameters{{Key: "title", Values: parse.Values{"Hello: World"}}}, `:title "Hello: World"`},
+		{parse.Parameters{{Key: "tangle", Values: parse.Values{"my file.txt"}}}, `:tangle "my file.txt"`},
+		{parse.Parameters{{Key: "var", Values: parse.Values{":x"}}}, `:var ":x"`},
+		{parse.Parameters{{Key: "var", Values: parse.Values{""}}}, `:var ""`},
+		{parse.Parameters{{Key: "var", Values: parse.Values{`say "hi" \o/`}}}, `:var "say \"hi\" \\o/"`},
+		{parse.Parameters{{Key: "var", Values: parse.Values{"a:b", "x=1"}}}, `:var a:b x=1`},
+	} {
+		fused := tc.params.FuseToNoweb()
+		if fused != tc.fused {
+			t.Errorf("%v fused into `%s`, expected `%s`", tc.params, fused, tc.fused)
+		}
+		if parsed := parse.ParseNowebArguments(fused); !reflect.DeepEqual(parsed, tc.params) {
+			t.Errorf("`%s` parsed back into %v, expected %v", fused, parsed, tc.params)
+		}
+	}
+}
+
+func TestNowebQuotingRoundTrip(t *testing.T) {
+	for _, args := range []string{
+		`:title "Hello: World"`,
+		`:tangle "my file.txt" :exports none`,
+		`:var "a \"quoted\" value"`,
+	} {
+		if fused := parse.ParseNowebArguments(args).FuseToNoweb(); fused != args {
+			t.Errorf("`%s` round-tripped into `%s`", args, fused)
+		}
+	}
+}
+
+func TestNowebArgumentsWithTabs(t *testing.T) {
+	expected := parse.ParseNowebArguments(":exports none :tangle main.go")
+	for _, args := range []string{
+		":exports\tnone :tangle main.go",
+		"\t:exports\t\tnone\t:tangle\tmain.go\t",
+		":exports none\n:tangle main.go",
+	} {
+		if parsed := parse.ParseNowebArguments(args); !reflect.DeepEqual(parsed, expected) {
+			t.Errorf("%q parsed into %v, expected %v", args, parsed, expected)
+		}
+	}
+
+	lang, params := parse.ParseOrgBeginSrc("#+begin_src\tsh\t:exports\tnone")
+	if lang != "sh" || !reflect.DeepEqual(params, parse.Parameters{{Key: "exports", Values: parse.Values{"none"}}}) {
+		t.Errorf("tab-separated begin line parsed into %s, %v", lang, params)
+	}
+}
+
+func TestParseNowebArgumentsMatrix(t *testing.T) {
+	type p = parse.Parameter
+	type vs = parse.Values
+	for args, expected := range map[string]parse.Parameters{
+		"":                           {},
+		"pos":                        {p{"", vs{"pos"}}},
+		"foo bar :exports none":      {p{"", vs{"foo", "bar"}}, p{"exports", vs{"none"}}},
+		":minipage":                  {p{"minipage", nil}},
+		":minipage :exports none":    {p{"minipage", nil}, p{"exports", vs{"none"}}},
+		":a:b c":                     {p{"a:b", vs{"c"}}},
+		"::x y":                      {p{":x", vs{"y"}}},
+		":a :: b":                    {p{"a", nil}, p{":", vs{"b"}}},
+		`:title "Hello: World" :k v`: {p{"title", vs{"Hello: World"}}, p{"k", vs{"v"}}},
+	} {
+		if parsed := parse.ParseNowebArguments(args); !reflect.DeepEqual(parsed, expected) {
+			t.Errorf("%q parsed into %#v, expected %#v", args, parsed, expected)
+		}
+	}
+}
+
+func TestParseOrgBeginSrcArguments(t *testing.T) {
+	line := `#+begin_src c++ -n :flags -O2 :var x="a: b"`
+	lang, params := parse.ParseOrgBeginSrc(line)
+	if lang != "c++" {
+		t.Errorf("language of `%s` is %s", line, lang)
+	}
+	if expected := parse.ParseNowebArguments(`-n :flags -O2 :var x="a: b"`); !reflect.DeepEqual(params, expected) {
+		t.Errorf("arguments of `%s` are %v, expected %v", line, params, expected)
+	}
+}
+
+func TestPositionalNowebArgumentsRoundTrip(t *testing.T) {
+	for _, args := range []string{"foo bar :exports none", "-n -r", "-n :exports none :minipage"} {
+		if fused := parse.ParseNowebArguments(args).FuseToNoweb(); fused != args {
+			t.Errorf("`%s` round-tripped into `%s`", args, fused)
+		}
+	}
+	parsetest.AssertRoundTrip(t, parse.OrgLang, []string{"#+begin_src sh -n :exports none", "echo", "#+end_src"})
+}
+
+func TestExpandNowebEmptyBlock(t *testing.T) {
+	matter, err := parse.OrgLang.Parse([]string{
+		"#+name: empty",
+		"#+begin_src sh",
+		"#+end_src",
+		"#+begin_src sh",
+		"echo before",
+		"<<empty>>",
+		"<<unknown>>",
+		"#+end_src",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expanded, err := parse.ExpandNoweb(parse.AttachAffiliated(matter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := expanded[len(expanded)-1].AsCode()
+	if expected := []string{"echo before", "<<unknown>>"}; !reflect.DeepEqual(code.Raw, expected) {
+		t.Errorf("reference to an empty block expanded into %q, expected %q", code.Raw, expected)
+	}
+}
@@ -3,6 +3,7 @@ package parse
 import (
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 ///////////////////////////
@@ -19,6 +20,32 @@ func (p str) StripLeftOf(s string) string {
 	return strings.TrimPrefix(s, string(p))
 }
 
+// IsPrefixFold is like IsPrefix, but ignores case.
+func (p str) IsPrefixFold(s string) bool {
+	return len(s) >= len(p) && strings.EqualFold(s[:len(p)], string(p))
+}
+
+// StripLeftOfFold is like StripLeftOf, but ignores case.
+func (p str) StripLeftOfFold(s string) string {
+	if p.IsPrefixFold(s) {
+		return s[len(p):]
+	}
+	return s
+}
+
+func (p str) IsSuffix(s string) bool {
+	return strings.HasSuffix(s, string(p))
+}
+
+func (p str) StripRightOf(s string) string {
+	return strings.TrimSuffix(s, string(p))
+}
+
+// HasAffix returns true when p is a prefix or a suffix of s.
+func (p str) HasAffix(s string) bool {
+	return p.IsPrefix(s) || p.IsSuffix(s)
+}
+
 func (set str) Trim(s string) string {
 	return strings.Trim(s, string(set))
 }
@@ -44,6 +71,36 @@ func (set str) First(s string) int {
 	return strings.IndexAny(s, string(set))
 }
 
+// Cut slices s around the first rune of the set, returning the text before
+// and after it.
+// When no rune of the set is in s, found is false and before is s.
+func (set str) Cut(s string) (before, after string, found bool) {
+	pos := set.First(s)
+	if pos == -1 {
+		return s, "", false
+	}
+	_, size := utf8.DecodeRuneInString(s[pos:])
+	return s[:pos], s[pos+size:], true
+}
+
+// SplitN slices s around the runes of the set, returning at most n substrings
+// like strings.SplitN, the last one being the unsplit remainder.
+func (set str) SplitN(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	res := []string{}
+	for n < 0 || len(res) < n-1 {
+		before, after, found := set.Cut(s)
+		if !found {
+			break
+		}
+		res = append(res, before)
+		s = after
+	}
+	return append(res, s)
+}
+
 // Skim returns the first index of s that is not in the set.
 // Returns -1 when the set intersects with s.
 func (set str) Skim(s string) int {
@@ -73,8 +130,6 @@ func (sep str) Join(s ...string) string {
 
 var spaces = str(" \t\n")
 
-// IDEA: object prefix and suffix library.
-
 ///////////////////////////
 // Object regexp library //
 ///////////////////////////
@@ -90,6 +145,44 @@ func re(s string) regex                  { return regex{regexp.MustCompile(s)} }
 func (r regex) Match(s string) bool      { return r.MatchString(s) }
 func (r regex) Groups(s string) []string { return r.FindStringSubmatch(s) }
 
+// ReplaceFunc replaces the matches of the regex in s by the result of fun,
+// which receives the groups of each match like Groups.
+// Unlike ReplaceAllStringFunc, the groups are those of the match in the
+// context of s, so anchors and word boundaries behave as expected.
+func (r regex) ReplaceFunc(s string, fun func([]string) string) string {
+	var res strings.Builder
+	last := 0
+	for _, loc := range r.FindAllStringSubmatchIndex(s, -1) {
+		groups := make([]string, len(loc)/2)
+		for i := range groups {
+			if loc[2*i] >= 0 {
+				groups[i] = s[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		res.WriteString(s[last:loc[0]])
+		res.WriteString(fun(groups))
+		last = loc[1]
+	}
+	res.WriteString(s[last:])
+	return res.String()
+}
+
+// NamedGroups returns the named groups matched in s, indexed by name.
+// Returns nil when s does not match.
+func (r regex) NamedGroups(s string) map[string]string {
+	groups := r.Groups(s)
+	if groups == nil {
+		return nil
+	}
+	res := map[string]string{}
+	for i, name := range r.SubexpNames() {
+		if name != "" {
+			res[name] = groups[i]
+		}
+	}
+	return res
+}
+
 //////////////////////////
 // Object slice library //
 //////////////////////////
@@ -110,3 +203,41 @@ func (s *slice[T]) Add(el ...T) *slice[T] {
 	*s = append(*s, el...)
 	return s
 }
+
+// Filter returns a new slice holding the elements satisfying pred.
+func (s slice[T]) Filter(pred Pred[T]) slice[T] {
+	return Filter(pred, s)
+}
+
+// Reduce accumulates the elements of the slice, starting from init.
+// Methods cannot have type parameters, so the accumulator is of the same type
+// as the elements; use the Reduce function otherwise.
+func (s slice[T]) Reduce(fun func(T, T) T, init T) T {
+	return Reduce(fun, init, s)
+}
+
+// IndexOf returns the index of the first element satisfying pred, -1 if there is
+// none.
+// Elements are matched with a predicate since T is not necessarily comparable.
+func (s slice[T]) IndexOf(pred Pred[T]) int {
+	for i, el := range s {
+		if pred(el) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Contains returns true if an element satisfies pred.
+func (s slice[T]) Contains(pred Pred[T]) bool {
+	return s.IndexOf(pred) != -1
+}
+
+// Reverse returns a new slice holding the elements in reverse order.
+func (s slice[T]) Reverse() slice[T] {
+	res := make(slice[T], len(s))
+	for i, el := range s {
+		res[len(s)-1-i] = el
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package parse
+
+import "testing"
+
+func TestStrAffixes(t *testing.T) {
+	for _, tc := range []struct {
+		affix, s         string
+		prefix, suffix   bool
+		stripped, before string
+	}{
+		{"#+", "#+title", true, false, "title", "#+title"},
+		{".org", "notes.org", false, true, "notes.org", "notes"},
+		{"ab", "abab", true, true, "ab", "ab"},
+		{"x", "", false, false, "", ""},
+		{"", "same", true, true, "same", "same"},
+	} {
+		p := str(tc.affix)
+		if p.IsPrefix(tc.s) != tc.prefix {
+			t.Errorf("`%s`.IsPrefix(`%s`) is %t", tc.affix, tc.s, !tc.prefix)
+		}
+		if p.IsSuffix(tc.s) != tc.suffix {
+			t.Errorf("`%s`.IsSuffix(`%s`) is %t", tc.affix, tc.s, !tc.suffix)
+		}
+		if p.HasAffix(tc.s) != (tc.prefix || tc.suffix) {
+			t.Errorf("`%s`.HasAffix(`%s`) is %t", tc.affix, tc.s, !(tc.prefix || tc.suffix))
+		}
+		if stripped := p.StripLeftOf(tc.s); stripped != tc.stripped {
+			t.Errorf("`%s`.StripLeftOf(`%s`) is `%s`, expected `%s`", tc.affix, tc.s, stripped, tc.stripped)
+		}
+		if before := p.StripRightOf(tc.s); before != tc.before {
+			t.Errorf("`%s`.StripRightOf(`%s`) is `%s`, expected `%s`", tc.affix, tc.s, before, tc.before)
+		}
+	}
+}
+
+func TestSliceIndexOf(t *testing.T) {
+	s := slc("a", "b", "a")
+	if i := s.IndexOf(func(el string) bool { return el == "a" }); i != 0 {
+		t.Errorf("first a found at %d", i)
+	}
+	if i := s.IndexOf(func(el string) bool { return el == "c" }); i != -1 {
+		t.Errorf("missing c found at %d", i)
+	}
+	if !s.Contains(func(el string) bool { return el == "b" }) {
+		t.Error("b not contained")
+	}
+}
@@ -2,19 +2,51 @@ package parse
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
+	"unicode/utf8"
 )
 
 ///////////////////
 // Text matching //
 ///////////////////
 
-var orgSectionRe = re(`^(\*+) (.+)$`)
+var orgStarPfx = str("*")
+var orgSectionRe = re(`^(?P<level>\*+) (?P<title>.+)$`)
+var orgPriorityRe = re(`^\[#([A-Za-z0-9])\](?:[ \t]+|$)`)
+var orgTagsRe = re(`(?:^|[ \t]+)(:(?:[\w@#%]+:)+)[ \t]*$`)
 var orgBeginSrcPfx = str("#+begin_src")
 var orgEndSrcPfx = str("#+end_src")
+var orgBeginSrcRe = re(`^(?i)([ \t]*)#\+begin_src[ \t]*(\S*)(.*)$`)
+var orgEndSrcRe = re(`^(?i)[ \t]*#\+end_src`)
 var orgPropertyPfx = str("#+")
 var orgBeginPfx = str("#+begin_")
+var orgBeginExamplePfx = str("#+begin_example")
+var orgEndExamplePfx = str("#+end_example")
+var orgBeginExportPfx = str("#+begin_export")
+var orgEndExportPfx = str("#+end_export")
+var orgFixedWidthRe = re(`^[ \t]*:(?: |$)`)
+var orgFixedWidthPfx = str(": ")
+var orgBeginQuotePfx = str("#+begin_quote")
+var orgEndQuotePfx = str("#+end_quote")
 var orgEndPfx = str("#+end_")
+var orgCommentRe = re(`^[ \t]*#(?:[ \t]|$)`)
+var orgBeginDrawerRe = re(`^[ \t]*:PROPERTIES:[ \t]*$`)
+var orgEndDrawerRe = re(`^[ \t]*:END:[ \t]*$`)
+var orgDrawerPropertyRe = re(`^[ \t]*:([^:\s]+):(?:[ \t]+(.*?))?[ \t]*$`)
+
+// orgAffiliatedKeywords are the metadata keywords affecting the element that
+// follows them, along with the keywords starting with `ATTR_`.
+var orgAffiliatedKeywords = []string{"CAPTION", "HEADER", "NAME", "PLOT", "RESULTS"}
+
+// OrgTodoKeywords are the keywords that can start the title of a section.
+var OrgTodoKeywords = []string{"TODO", "DONE"}
+
+// orgTagsColumn is the column at which Org right-aligns tags.
+const orgTagsColumn = 77
+
+// orgPropertyFormat is the default format of drawer properties in Org.
+const orgPropertyFormat = "%-10s %s"
 
 ////////////////
 // Primitives //
@@ -23,7 +55,7 @@ var orgEndPfx = str("#+end_")
 // ParseOrgBeginSrc parses the language and noweb parameters of a `#+begin_src`
 // line.
 func ParseOrgBeginSrc(line string) (string, Parameters) {
-	line = orgBeginSrcPfx.StripLeftOf(line)
+	line = orgBeginSrcPfx.StripLeftOfFold(spaces.Trim(line))
 	line = spaces.Trim(line)
 	pos := spaces.First(line)
 	if pos == -1 {
@@ -32,39 +64,249 @@ func ParseOrgBeginSrc(line string) (string, Parameters) {
 	return line[:pos], ParseNowebArguments(line[pos:])
 }
 
+// orgListItem returns true if line is a list item.
+// Star bullets must be indented, so that they are not confused with sections.
+func orgListItem(line string) bool {
+	return listItemRe.Match(line) && !orgStarPfx.IsPrefix(line)
+}
+
+// orgBlockType returns the type of a block from its begin or end line, e.g.
+// quote for `#+begin_quote`.
+func orgBlockType(pfx str, line string) string {
+	line = pfx.StripLeftOfFold(line)
+	if pos := spaces.First(line); pos != -1 {
+		line = line[:pos]
+	}
+	return strings.ToLower(line)
+}
+
+////////////
+// Takers //
+////////////
+
+// OrgBlockTake builds an ErrorTaker function that will take a block whose
+// first line satisfies first, up to the end line of the same type.
+// Blocks nested inside are skipped and an error is returned when the block is
+// closed by an end line of another type.
+func OrgBlockTake(first Pred[string]) ErrorTaker {
+	return func(lines []string) (int, error) {
+		if len(lines) == 0 || !first(lines[0]) || !orgBeginPfx.IsPrefixFold(lines[0]) {
+			return 0, nil
+		}
+		open := []string{orgBlockType(orgBeginPfx, lines[0])}
+		for i, line := range lines[1:] {
+			switch {
+			case orgBeginPfx.IsPrefixFold(line):
+				open = append(open, orgBlockType(orgBeginPfx, line))
+			case orgEndPfx.IsPrefixFold(line):
+				kind := orgBlockType(orgEndPfx, line)
+				if kind != open[len(open)-1] {
+					return 0, fmt.Errorf("mismatched block: `#+begin_%s` is closed by `%s`", open[len(open)-1], line)
+				}
+				if open = open[:len(open)-1]; len(open) == 0 {
+					return i + 2, nil // Include begin and end lines.
+				}
+			}
+		}
+		return 0, fmt.Errorf("unterminated %s block, no `#+end_%[1]s` line closes it", open[0])
+	}
+}
+
+// OrgCodeTake is an ErrorTaker for code blocks.
+// Indented blocks are only taken when their content and their end line are
+// indented like their begin line, since their indentation could not be
+// restored otherwise.
+func OrgCodeTake(lines []string) (int, error) {
+	res, err := RegexpBetweenErrorTake(orgBeginSrcRe, orgEndSrcRe)(lines)
+	if err != nil {
+		return 0, fmt.Errorf("unterminated src block, no `%s` line closes it", orgEndSrcPfx)
+	}
+	if res == 0 {
+		return 0, nil
+	}
+	indent := str(orgBeginSrcRe.Groups(lines[0])[1])
+	empty := func(line string) bool { return line == "" }
+	if slc(lines[1:res]...).Contains(Nor(indent.IsPrefix, empty)) ||
+		!orgEndSrcPfx.IsPrefixFold(indent.StripLeftOf(lines[res-1])) {
+		return 0, nil
+	}
+	return res, nil
+}
+
+// orgProseTake takes prose, stopping before indented code blocks, including
+// unterminated ones so that they are reported.
+func orgProseTake(lines []string) int {
+	res := TrailingTake(spaces.Intersects, Nor(orgSectionRe.Match, orgPropertyPfx.IsPrefix, orgBeginDrawerRe.Match, orgListItem, orgFootnoteRe.Match, orgCommentRe.Match, orgFixedWidthRe.Match))(lines)
+	for i := 0; i < res; i++ {
+		if taken, err := OrgCodeTake(lines[i:]); taken == 0 && err == nil {
+			continue
+		}
+		for i > 0 && spaces.Intersects(lines[i-1]) {
+			i--
+		}
+		return i
+	}
+	return res
+}
+
 ////////////
 // Makers //
 ////////////
 
+// OrgSectionMk makes a section element from an Org heading, separating the
+// TODO keyword, the priority and the tags from the title.
+func OrgSectionMk(lines []string) ElementImpl {
+	res := ReSectionMake(orgSectionRe)(lines).(SectionElement)
+	title := res.Title
+
+	keyword, rest, _ := strings.Cut(title, " ")
+	for _, todo := range OrgTodoKeywords {
+		if keyword == todo {
+			res.Todo = todo
+			title = strings.TrimLeft(rest, " ")
+		}
+	}
+	if groups := orgPriorityRe.Groups(title); groups != nil {
+		res.Priority = groups[1]
+		title = title[len(groups[0]):]
+	}
+	if loc := orgTagsRe.FindStringSubmatchIndex(title); loc != nil {
+		res.Tags = str(":").Fields(title[loc[2]:loc[3]])
+		title = title[:loc[0]]
+	}
+
+	res.Title = title
+	return res
+}
+
 // OrgCodeMk makes a code element from Org lines.
-func OrgCodeMk(lines []string) ElementImpl {
-	lang, params := ParseOrgBeginSrc(lines[0])
+// The indentation of the block is removed from its content.
+var OrgCodeMk = ReBetweenMake(orgBeginSrcRe, func(groups []string, inner []string) ElementImpl {
 	return CodeElement{
-		Raw:    lines[1 : len(lines)-1],
-		Lang:   lang,
-		Params: params,
+		Raw:    Map(str(groups[1]).StripLeftOf, inner),
+		Lang:   groups[2],
+		Params: ParseNowebArguments(groups[3]),
+		Indent: groups[1],
 	}
+})
+
+// orgDescriptionRe matches the first line of a description item, capturing the
+// term, the separator and the start of the definition.
+var orgDescriptionRe = re(`^(.*?\S)([ \t]+::(?:[ \t]+|$))(.*)$`)
+
+// OrgListMk makes a list element from Org lines, splitting the term of the
+// unordered description items from their definition.
+func OrgListMk(lines []string) ElementImpl {
+	res := ListMk(lines).(ListElement)
+	for i, item := range res.Items {
+		if listOrdered(item.Bullet) {
+			continue
+		}
+		if groups := orgDescriptionRe.Groups(item.Lines[0]); groups != nil {
+			res.Items[i].Term, res.Items[i].TermSep, res.Items[i].Lines[0] = groups[1], groups[2], groups[3]
+		}
+	}
+	return res
 }
 
 // OrgBlockMk makes a block element from Org lines.
+// The type of block is lowercased, its parameters are kept as is.
 func OrgBlockMk(lines []string) ElementImpl {
+	kind := orgBeginPfx.StripLeftOfFold(lines[0])
+	params := ""
+	if pos := spaces.First(kind); pos != -1 {
+		kind, params = kind[:pos], kind[pos:]
+	}
 	return BlockElement{
 		Raw:  lines[1 : len(lines)-1],
-		Type: orgBeginPfx.StripLeftOf(lines[0]),
+		Type: strings.ToLower(kind) + params,
+	}
+}
+
+// OrgExampleMk makes an example element from Org lines.
+func OrgExampleMk(lines []string) ElementImpl {
+	return ExampleElement{Raw: lines[1 : len(lines)-1], Style: "block"}
+}
+
+// OrgExportMk makes an export element from Org lines.
+func OrgExportMk(lines []string) ElementImpl {
+	return ExportElement{
+		Raw:     lines[1 : len(lines)-1],
+		Backend: spaces.Trim(orgBeginExportPfx.StripLeftOfFold(lines[0])),
+	}
+}
+
+// OrgFixedWidthBk strips the colon (and the space following it) of a
+// fixed-width line.
+func OrgFixedWidthBk(line string) string {
+	line = strings.TrimLeft(line, " \t")
+	if orgFixedWidthPfx.IsPrefix(line) {
+		return orgFixedWidthPfx.StripLeftOf(line)
+	}
+	return str(":").StripLeftOf(line)
+}
+
+// OrgFixedWidthMk makes an example element from fixed-width lines stripped of
+// their colon.
+func OrgFixedWidthMk(lines []string) ElementImpl {
+	return ExampleElement{Raw: lines, Style: "fixed"}
+}
+
+// OrgQuoteMk makes a quote element from Org lines.
+func OrgQuoteMk(lines []string) ElementImpl {
+	return QuoteElement{Raw: lines[1 : len(lines)-1], Level: 1}
+}
+
+// OrgDrawerMk makes a drawer element from Org lines.
+// Lines that are not properties are only kept in the raw lines.
+func OrgDrawerMk(lines []string) ElementImpl {
+	return DrawerElement{Name: "PROPERTIES", Props: orgDrawerProps(lines), Raw: lines}
+}
+
+// orgDrawerProps returns the properties of the lines of a drawer.
+func orgDrawerProps(lines []string) Parameters {
+	res := Parameters{}
+	for _, line := range lines[1 : len(lines)-1] {
+		groups := orgDrawerPropertyRe.Groups(line)
+		if groups == nil {
+			continue
+		}
+		var values Values
+		if groups[2] != "" {
+			values = Values{groups[2]}
+		}
+		res.Add(groups[1], values)
+	}
+	return res
+}
+
+// OrgCommentMk makes a comment element from Org comment lines.
+func OrgCommentMk(lines []string) ElementImpl {
+	return CommentElement{Raw: lines, Style: "line"}
+}
+
+// OrgCommenter turns lines into an Org comment.
+func OrgCommenter(lines []string) ElementImpl {
+	res := CommentElement{Style: "line"}
+	for _, line := range lines {
+		res.Raw = append(res.Raw, spaces.TrimRight("# "+line))
 	}
+	return res
 }
 
 // OrgPropertyMk makes a metadata element from an Org property line.
-func OrgPropertyMk(lines []string) ElementImpl {
-	line := lines[0]
-	split := strings.SplitN(line, " ", 2)
-	name := split[0]
-	if name[len(name)-1] == ':' {
-		name = name[:len(name)-1]
+// The value is kept verbatim as a single positional parameter, since most
+// keywords are not made of noweb arguments and their quotes are meaningful.
+func OrgPropertyMk(line string) ElementImpl {
+	name, args, _ := spaces.Cut(line)
+	res := MetadataElement{Name: str(":").StripRightOf(name), Scope: ScopeDocument}
+	keyword := strings.ToUpper(res.Name)
+	affiliated := func(candidate string) bool { return candidate == keyword }
+	if str("ATTR_").IsPrefix(keyword) || slc(orgAffiliatedKeywords...).Contains(affiliated) {
+		res.Scope = ScopeElement
 	}
-	res := MetadataElement{Name: name, Scope: ScopeDocument}
-	if len(split) == 2 {
-		res.Data = ParseNowebArguments(split[1])
+	if args = spaces.Trim(args); args != "" {
+		res.Data = Parameters{{Key: "", Values: Values{args}}}
 	}
 	return res
 }
@@ -76,76 +318,458 @@ func OrgPropertyMk(lines []string) ElementImpl {
 // OrgRules is a sequence of rules able to parse an Org file.
 var OrgRules = Rules{
 	Rule{ // Section, hierarchical delimiter of the document.
+		Name: "section",
 		Take: FirstTake(orgSectionRe.Match),
 		Bake: NoBk,
-		Make: ReSectionMake(orgSectionRe),
+		Make: OrgSectionMk,
 	},
 	Rule{ // Code, content meant for machine consumption.
-		Take: BetweenTake(orgBeginSrcPfx.IsPrefix, orgEndSrcPfx.IsPrefix),
+		Name:      "code",
+		ErrorTake: OrgCodeTake,
+		Bake:      NoBk,
+		Make:      OrgCodeMk,
+	},
+	Rule{ // Example, verbatim content that is never tangled.
+		Name:      "example",
+		ErrorTake: BetweenErrorTake(orgBeginExamplePfx.IsPrefixFold, orgEndExamplePfx.IsPrefixFold),
+		Bake:      NoBk,
+		Make:      OrgExampleMk,
+	},
+	Rule{ // Export, verbatim content meant for a single backend.
+		Name:      "export",
+		ErrorTake: BetweenErrorTake(orgBeginExportPfx.IsPrefixFold, orgEndExportPfx.IsPrefixFold),
+		Bake:      NoBk,
+		Make:      OrgExportMk,
+	},
+	Rule{ // Fixed-width lines, typically the results of code blocks.
+		Name: "fixed-width",
+		Take: GreedyTake(orgFixedWidthRe.Match),
+		Bake: OrgFixedWidthBk,
+		Make: OrgFixedWidthMk,
+	},
+	Rule{ // Quote, content from another source.
+		Name:      "quote",
+		ErrorTake: OrgBlockTake(orgBeginQuotePfx.IsPrefixFold),
+		Bake:      NoBk,
+		Make:      OrgQuoteMk,
+	},
+	Rule{ // Other kind of blocks, like verse blocks.
+		Name:      "block",
+		ErrorTake: OrgBlockTake(orgBeginPfx.IsPrefixFold),
+		Bake:      NoBk,
+		Make:      OrgBlockMk,
+	},
+	Rule{ // Properties attached to the preceding section.
+		Name:      "drawer",
+		ErrorTake: BetweenErrorTake(orgBeginDrawerRe.Match, orgEndDrawerRe.Match),
+		Bake:      NoBk,
+		Make:      OrgDrawerMk,
+	},
+	Rule{ // List, whose items can be nested.
+		Name: "list",
+		Take: ListTake(orgStarPfx.IsPrefix),
+		Bake: NoBk,
+		Make: OrgListMk,
+	},
+	Rule{ // Footnote, referenced from elsewhere in the document.
+		Name: "footnote",
+		Take: FootnoteTake(orgFootnoteRe.Match, Nor(orgSectionRe.Match, orgPropertyPfx.IsPrefix)),
 		Bake: NoBk,
-		Make: OrgCodeMk,
+		Make: ReFootnoteMake(orgFootnoteRe),
 	},
-	Rule{ // Other kind of blocks, like quote blocks.
-		// This taker doesn't ensure that the begin and end block are matching.
-		// It will work fine assuming no wild ^#+end_ is present inside blocks.
-		// This is bound to happen eventually so I guess this is a TODO.
-		Take: BetweenTake(orgBeginPfx.IsPrefix, orgEndPfx.IsPrefix),
+	Rule{ // Comment, content that is not exported.
+		Name: "comment",
+		Take: GreedyTake(orgCommentRe.Match),
 		Bake: NoBk,
-		Make: OrgBlockMk,
+		Make: OrgCommentMk,
 	},
 	Rule{ // Metadata about the document.
-		Take: FirstTake(orgPropertyPfx.IsPrefix),
+		Name: "metadata",
+		Take: MonoTake(And(orgPropertyPfx.IsPrefix, Nor(orgBeginPfx.IsPrefixFold))),
 		Bake: orgPropertyPfx.StripLeftOf,
-		Make: OrgPropertyMk,
+		Make: MonoMake(OrgPropertyMk),
 	},
 	SpaceRule, // Whitespace, content that can typically be ignored.
 	Rule{ // Prose, content meant for human consumption.
-		Take: TrailingTake(spaces.Intersects, nor(orgSectionRe.Match, orgPropertyPfx.IsPrefix)),
+		Name: "prose",
+		Take: orgProseTake,
 		Bake: NoBk,
 		Make: ProseMk,
 	},
 }
 
-// OrgFuser can reconstruct the lines of an Org document from parsed elements.
-func OrgFuser(matter Elements) ([]string, error) {
+// OrgResultsRefiner attaches to code blocks the results following them, after
+// one blank line and a `#+RESULTS:` line, as Org inserts them.
+// Named results are only attached to the code block of the same name, given by
+// a `#+NAME:` line preceding it.
+func OrgResultsRefiner(matter Elements) (Elements, error) {
+	res := Elements{}
+	for i := 0; i < len(matter); i++ {
+		part := matter[i]
+		code, ok := part.AsCode()
+		if !ok || i+3 >= len(matter) || !orgResultsFollow(orgBlockName(part, res), matter[i+1:i+3]) {
+			res = append(res, part)
+			continue
+		}
+		results, rest, ok, err := orgResultLines(matter[i+3])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			res = append(res, part)
+			continue
+		}
+
+		code.Results = results
+		original := slice[string]{}
+		for _, merged := range matter[i : i+3] {
+			original.Add(merged.Original...)
+		}
+		res = append(res, Element{
+			ElementImpl: code,
+			Span:        Span{StartLine: part.Span.StartLine, EndLine: matter[i+3].Span.StartLine + len(results) - 1},
+			Original:    *original.Add(results...),
+		})
+		res = append(res, rest...)
+		i += 3
+	}
+	return res, nil
+}
+
+// AttachAffiliated moves the metadata elements affecting the element that
+// follows them, like Org `#+CAPTION:` lines, to the Affiliated parameters of
+// this element, in order.
+// The element is also given the name of a `#+NAME:` line.
+// Metadata elements that are not directly followed by an element they can
+// affect are kept.
+func AttachAffiliated(matter Elements) Elements {
+	res := Elements{}
+	for i := 0; i < len(matter); i++ {
+		end := i
+		for end < len(matter) && orgAffiliated(matter[end]) {
+			end++
+		}
+		if end == i {
+			res = append(res, matter[i])
+			continue
+		}
+		if end == len(matter) || !orgAffiliable(matter[end]) {
+			res = append(res, matter[i:end]...)
+			i = end - 1
+			continue
+		}
+
+		part := matter[end]
+		part.Affiliated = Parameters{}
+		original := slice[string]{}
+		for _, affiliated := range matter[i:end] {
+			meta, _ := affiliated.AsMetadata()
+			var values Values
+			if !meta.Data.Empty() {
+				values = Values{orgKeywordValue(meta.Data)}
+			}
+			part.Affiliated = append(part.Affiliated, Parameter{Key: meta.Name, Values: values})
+			if strings.EqualFold(meta.Name, "NAME") {
+				part.Name = orgKeywordValue(meta.Data)
+			}
+			original.Add(affiliated.Original...)
+		}
+		part.Span.StartLine = matter[i].Span.StartLine
+		if part.Original != nil {
+			part.Original = *original.Add(part.Original...)
+		}
+		res = append(res, part)
+		i = end
+	}
+	return res
+}
+
+// orgAffiliated returns true if an element is metadata affecting the element
+// that follows it.
+func orgAffiliated(part Element) bool {
+	meta, ok := part.AsMetadata()
+	return ok && meta.Scope == ScopeElement
+}
+
+// orgAffiliable returns true if an element can be affected by metadata.
+func orgAffiliable(part Element) bool {
+	switch part.ElementImpl.(type) {
+	case SpaceElement, SectionElement, MetadataElement:
+		return false
+	}
+	return true
+}
+
+// orgFuseAffiliated reconstructs the metadata lines affecting an element,
+// the name of the element taking precedence over its `#+NAME:` line.
+func orgFuseAffiliated(part Element) []string {
+	isName := func(param Parameter) bool { return strings.EqualFold(param.Key, "NAME") }
+	name := slc(part.Affiliated...).IndexOf(isName)
 	res := slice[string]{}
-	for _, part := range matter {
+	if part.Name != "" && name == -1 {
+		res.Add("#+name: " + part.Name)
+	}
+	for i, param := range part.Affiliated {
+		values := param.Values
+		if isName(param) {
+			if i != name || part.Name == "" {
+				continue
+			}
+			values = Values{part.Name}
+		}
+		res.Add(spaces.TrimRight("#+" + param.Key + ": " + strings.Join(values, " ")))
+	}
+	return res
+}
+
+// orgBlockName returns the name of an element, given either by AttachAffiliated or
+// by the last of the preceding elements, if it is a `#+NAME:` line.
+func orgBlockName(part Element, preceding Elements) string {
+	if part.Name != "" {
+		return part.Name
+	}
+	if len(preceding) == 0 {
+		return ""
+	}
+	meta, ok := preceding[len(preceding)-1].AsMetadata()
+	if !ok || !strings.EqualFold(meta.Name, "NAME") {
+		return ""
+	}
+	return orgMetadataValue(meta)
+}
+
+// orgMetadataValue returns the value of a metadata line having exactly one
+// value, or the empty string.
+func orgMetadataValue(meta MetadataElement) string {
+	if len(meta.Data) != 1 || meta.Data[0].Key != "" || len(meta.Data[0].Values) != 1 {
+		return ""
+	}
+	return meta.Data[0].Values[0]
+}
+
+// orgKeywordValue reconstructs the value of a keyword line.
+// Values parsed from Org are written verbatim, while the parameters of
+// metadata coming from other languages are fused as noweb arguments.
+func orgKeywordValue(data Parameters) string {
+	if len(data) == 1 && data[0].Key == "" {
+		return data.FuseVerbatim()
+	}
+	return data.FuseToNoweb()
+}
+
+// orgResultsFollow returns true if the elements are the blank line and the
+// `#+RESULTS:` line introducing the results of the code block of the given
+// name.
+func orgResultsFollow(name string, matter Elements) bool {
+	space, ok := matter[0].ElementImpl.(SpaceElement)
+	if !ok || len(space.Raw) != 1 || space.Raw[0] != "" {
+		return false
+	}
+	meta, ok := matter[1].AsMetadata()
+	if !ok || !strings.EqualFold(meta.Name, "RESULTS") {
+		return false
+	}
+	if meta.Data.Empty() {
+		return name == ""
+	}
+	return name != "" && orgMetadataValue(meta) == name
+}
+
+// orgResultLines returns the original lines of an element holding results.
+// A `:results:` drawer is parsed as prose that can continue after the drawer,
+// in which case the elements following the drawer are also returned.
+func orgResultLines(part Element) ([]string, Elements, bool, error) {
+	if part.Original == nil {
+		return nil, nil, false, nil
+	}
+	switch p := part.ElementImpl.(type) {
+	case CodeElement, ExampleElement, ExportElement, ListElement, BlockElement, QuoteElement:
+		return part.Original, nil, true, nil
+	case ProseElement:
+		if !strings.EqualFold(spaces.Trim(p.Raw[0]), ":results:") {
+			break
+		}
+		for i, line := range part.Original {
+			if strings.EqualFold(spaces.Trim(line), ":end:") {
+				rest, err := OrgRules.parseFrom(part.Original[i+1:], part.Span.StartLine+i+1)
+				return part.Original[:i+1], rest, true, err
+			}
+		}
+	}
+	return nil, nil, false, nil
+}
+
+// orgHeading reconstructs the line of a section, with its tags right-aligned.
+func orgHeading(s SectionElement) string {
+	stars := strings.Repeat("*", s.Level)
+	res := stars
+	if s.Todo != "" {
+		res += " " + s.Todo
+	}
+	if s.Priority != "" {
+		res += " [#" + s.Priority + "]"
+	}
+	if s.Title != "" || res == stars {
+		res += " " + s.Title
+	}
+	if len(s.Tags) == 0 {
+		return res
+	}
+	tags := ":" + strings.Join(s.Tags, ":") + ":"
+	pad := orgTagsColumn - utf8.RuneCountInString(res) - len(tags)
+	if pad < 1 {
+		pad = 1
+	}
+	return res + strings.Repeat(" ", pad) + tags
+}
+
+// OrgStreamFuser can reconstruct the lines of an Org document from parsed elements.
+// Keywords delimiting blocks are written in lowercase, whatever their case when
+// parsed.
+func OrgStreamFuser(matter Elements, emit Emitter) error {
+	for i, part := range matter {
+		emit(orgFuseAffiliated(part)...)
 		switch p := part.ElementImpl.(type) {
 		case CodeElement:
-			begin := string(orgBeginSrcPfx) + " " + p.Lang
+			begin := p.Indent + string(orgBeginSrcPfx)
+			if p.Lang != "" {
+				begin += " " + p.Lang
+			}
 			if len(p.Params) > 0 {
 				begin += " " + p.Params.FuseToNoweb()
 			}
-			res.Add(begin)
-			res.Add(p.Raw...)
-			res.Add(string(orgEndSrcPfx))
+			emit(begin)
+			for _, line := range p.Raw {
+				if line != "" {
+					line = p.Indent + line
+				}
+				emit(line)
+			}
+			emit(p.Indent + string(orgEndSrcPfx))
+			if p.Results != nil {
+				results := "#+RESULTS:"
+				if name := orgBlockName(part, matter[:i]); name != "" {
+					results += " " + name
+				}
+				emit("", results)
+				emit(p.Results...)
+			}
 
 		case ProseElement:
-			res.Add(p.Raw...)
+			emit(p.Raw...)
+
+		case MarkupElement:
+			emit(p.Lines()...)
 
 		case MetadataElement:
 			prop := "#+" + p.Name + ":"
 			if !p.Data.Empty() {
-				prop += " " + p.Data.FuseToNoweb()
+				prop += " " + orgKeywordValue(p.Data)
 			}
-			res.Add(prop)
+			emit(prop)
 
 		case SectionElement:
-			res.Add(strings.Repeat("*", p.Level) + " " + p.Title)
+			emit(orgHeading(p))
 
 		case SpaceElement:
-			res.Add(p.Raw...)
+			emit(p.Raw...)
 
 		case BlockElement:
-			res.Add(string(orgBeginPfx) + p.Type)
-			res.Add(p.Raw...)
-			res.Add(string(orgEndPfx) + p.Type)
+			kind, _, _ := spaces.Cut(p.Type)
+			emit(string(orgBeginPfx) + p.Type)
+			emit(p.Raw...)
+			emit(string(orgEndPfx) + kind)
+
+		case ExampleElement:
+			if p.Style == "fixed" {
+				for _, line := range p.Raw {
+					emit(spaces.TrimRight(string(orgFixedWidthPfx) + line))
+				}
+				break
+			}
+			emit(string(orgBeginExamplePfx))
+			emit(p.Raw...)
+			emit(string(orgEndExamplePfx))
+
+		case ExportElement:
+			begin := string(orgBeginExportPfx)
+			if p.Backend != "" {
+				begin += " " + p.Backend
+			}
+			emit(begin)
+			emit(p.Raw...)
+			emit(string(orgEndExportPfx))
+
+		case QuoteElement:
+			for i := 0; i < p.Level; i++ {
+				emit(string(orgBeginQuotePfx))
+			}
+			emit(p.Raw...)
+			for i := 0; i < p.Level; i++ {
+				emit(string(orgEndQuotePfx))
+			}
+
+		case CommentElement:
+			emit(p.Raw...)
+
+		case FootnoteElement:
+			emit(fuseFootnote("[fn:"+p.Label+"]", p)...)
+
+		case ListElement:
+			emit(fuseList(p, "[X]")...)
+
+		case DrawerElement:
+			if len(p.Raw) >= 2 && reflect.DeepEqual(orgDrawerProps(p.Raw), p.Props) {
+				emit(p.Raw...)
+				break
+			}
+			emit(":" + p.Name + ":")
+			for _, prop := range p.Props {
+				emit(spaces.TrimRight(fmt.Sprintf(orgPropertyFormat, ":"+prop.Key+":", strings.Join(prop.Values, " "))))
+			}
+			emit(":END:")
 
 		default:
-			return nil, fmt.Errorf("no org fuser for %T", part.ElementImpl)
+			return fuseError("org", part)
 		}
 	}
-	return res, nil
+	return nil
+}
+
+// OrgFuser is like OrgStreamFuser, but returns the lines.
+func OrgFuser(matter Elements) ([]string, error) {
+	return CollectFuse(OrgStreamFuser, matter)
+}
+
+// FuseOptions configures OrgFuseWith.
+type FuseOptions struct {
+	Indent     bool   // Indent code blocks to match the level of their section.
+	IndentUnit string // Indentation of one level, made of spaces or tabs; two spaces when empty.
+}
+
+// OrgFuseWith is like OrgFuser, but can replace the indentation of code blocks
+// according to the options.
+func OrgFuseWith(matter Elements, opts FuseOptions) ([]string, error) {
+	if !opts.Indent {
+		return OrgFuser(matter)
+	}
+	unit := opts.IndentUnit
+	if unit == "" {
+		unit = "  "
+	}
+	indented := make(Elements, len(matter))
+	level := 0
+	for i, part := range matter {
+		switch p := part.ElementImpl.(type) {
+		case SectionElement:
+			level = p.Level
+		case CodeElement:
+			p.Indent = strings.Repeat(unit, level)
+			part.ElementImpl = p
+		}
+		indented[i] = part
+	}
+	return OrgFuser(indented)
 }
 
 // OrgLang holds information needed to manipulate Org files.
@@ -154,4 +778,8 @@ var OrgLang = Language{
 	Extensions:  []string{".org"},
 	Parser:      OrgRules,
 	Fuse:        OrgFuser,
+	Stream:      OrgStreamFuser,
+	Refine:      OrgResultsRefiner,
+	Markers:     Or(orgSectionRe.Match, orgPropertyPfx.IsPrefix),
+	Comment:     OrgCommenter,
 }
@@ -0,0 +1,151 @@
+package parse_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mooss/litlib/parse"
+	"github.com/mooss/litlib/parse/parsetest"
+)
+
+func FuzzOrgParse(f *testing.F) {
+	for _, snippet := range parsetest.OrgCorpus {
+		f.Add([]byte(strings.Join(snippet, "\n")))
+	}
+	documents, _ := filepath.Glob("../../*.org") // The sample documents of the repository.
+	for _, document := range documents {
+		if data, err := os.ReadFile(document); err == nil {
+			f.Add(data)
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parsetest.CheckParse(t, parse.OrgLang, data)
+	})
+}
+
+func TestOrgBlockMismatchedEnd(t *testing.T) {
+	_, err := parse.OrgLang.Parse([]string{"#+begin_quote", "Quoted.", "#+end_example"})
+	if err == nil || !strings.Contains(err.Error(), "`#+begin_quote` is closed by `#+end_example`") {
+		t.Errorf("quote closed by the end of an example gives error %v", err)
+	}
+}
+
+func TestOrgSequentialBlocks(t *testing.T) {
+	matter, err := parse.OrgLang.Parse([]string{
+		"#+begin_quote",
+		"Quoted.",
+		"#+end_quote",
+		"#+begin_verse",
+		"Verse.",
+		"#+end_verse",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matter) != 2 || matter[0].TypeName() != "quote" || matter[1].TypeName() != "block" {
+		t.Errorf("sequential blocks parsed into %v", parsetest.Repr(matter))
+	}
+}
+
+func TestOrgUnterminatedBlocks(t *testing.T) {
+	for kind, begin := range map[string]string{
+		"src":     "#+begin_src sh",
+		"example": "#+begin_example",
+		"quote":   "#+begin_quote",
+	} {
+		_, err := parse.OrgLang.Parse([]string{"* Title", "", begin, "echo", "no end"})
+		var parseErr parse.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("unterminated %s block gives error %v", kind, err)
+			continue
+		}
+		if parseErr.Line != 3 || parseErr.Content != begin || !strings.Contains(err.Error(), "unterminated") {
+			t.Errorf("unterminated %s block gives error %v", kind, err)
+		}
+	}
+}
+
+func TestOrgIsBlock(t *testing.T) {
+	matter, err := parse.OrgLang.Parse([]string{
+		"#+begin_example", "Example.", "#+end_example",
+		"#+begin_quote", "Quote.", "#+end_quote",
+		"#+begin_export html", "<br>", "#+end_export",
+		"#+begin_verse", "Verse.", "#+end_verse",
+		"#+begin_src sh", "echo", "#+end_src",
+		"Prose.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"example": true, "quote": true, "export": true, "block": true, "code": false, "prose": false}
+	if len(matter) != len(expected) {
+		t.Fatalf("blocks parsed into %v", parsetest.Repr(matter))
+	}
+	for _, part := range matter {
+		if part.IsBlock() != expected[part.TypeName()] {
+			t.Errorf("IsBlock of %s is %t", part.TypeName(), part.IsBlock())
+		}
+	}
+}
+
+func TestOrgKeywordsVerbatim(t *testing.T) {
+	for _, input := range [][]string{
+		{`#+TITLE: Say "hello" now`},
+		{`#+INCLUDE: "chapter.org" :lines "5-10"`},
+		{`#+CAPTION: A caption: with "quotes"`, "| a |"},
+	} {
+		parsetest.AssertRoundTrip(t, parse.OrgLang, input)
+
+		matter, err := parse.OrgLang.Parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fused, err := parse.OrgLang.Fuse(parse.AttachAffiliated(matter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fused, input) {
+			t.Errorf("%q fused with affiliated keywords into %q", input, fused)
+		}
+	}
+}
+
+func TestResolveIncludesQuotedArguments(t *testing.T) {
+	fsys := fstest.MapFS{"chapter.org": {Data: []byte("one\ntwo\nthree\nfour\n")}}
+	matter, err := parse.OrgLang.Parse([]string{`#+INCLUDE: "chapter.org" :lines "2-4"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	included, err := parse.ResolveIncludes(matter, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prose, _ := included[0].AsProse(); len(included) != 1 || !reflect.DeepEqual(prose.Raw, []string{"two", "three"}) {
+		t.Errorf("lines 2-4 included as %v", parsetest.Repr(included))
+	}
+}
+
+func TestOrgDrawerRoundTrip(t *testing.T) {
+	input := []string{"* Section", "  :PROPERTIES:", "  :ID: abc", "  Not a property.", "  :CUSTOM_ID:   intro", "  :END:"}
+	parsetest.AssertRoundTrip(t, parse.OrgLang, input)
+
+	matter, err := parse.OrgLang.Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawer := matter[1].ElementImpl.(parse.DrawerElement)
+	(*drawer.Props.Get("ID"))[0] = "xyz"
+	matter[1].ElementImpl = drawer
+	fused, err := parse.OrgLang.Fuse(matter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"* Section", ":PROPERTIES:", ":ID:       xyz", ":CUSTOM_ID: intro", ":END:"}; !reflect.DeepEqual(fused, expected) {
+		t.Errorf("modified drawer fused into %q, expected %q", fused, expected)
+	}
+}
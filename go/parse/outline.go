@@ -0,0 +1,120 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ElementsToYAML produces a YAML outline of the structure of a document, meant
+// to be read and diffed rather than parsed back.
+// Sections are nested, code is described by its language and parameters and
+// other elements are summarised by their first line.
+func ElementsToYAML(matter Elements) ([]byte, error) {
+	tree, err := BuildTree(matter)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	yamlContent(&buf, "", tree.Content)
+	yamlSections(&buf, "", tree.Children)
+	return buf.Bytes(), nil
+}
+
+// yamlSections writes sections as a YAML sequence.
+func yamlSections(buf *bytes.Buffer, indent string, nodes []*Node) {
+	for _, node := range nodes {
+		fmt.Fprintf(buf, "%s- section: %s\n", indent, yamlString(node.Section.Title))
+		fmt.Fprintf(buf, "%s  level: %d\n", indent, node.Section.Level)
+		if len(node.Content) > 0 {
+			fmt.Fprintf(buf, "%s  content:\n", indent)
+			yamlContent(buf, indent+"    ", node.Content)
+		}
+		if len(node.Children) > 0 {
+			fmt.Fprintf(buf, "%s  sections:\n", indent)
+			yamlSections(buf, indent+"    ", node.Children)
+		}
+	}
+}
+
+// yamlContent writes the elements of a section as a YAML sequence.
+// Whitespace is skipped.
+func yamlContent(buf *bytes.Buffer, indent string, matter Elements) {
+	for _, part := range matter {
+		switch p := part.ElementImpl.(type) {
+		case SpaceElement:
+		case CodeElement:
+			fmt.Fprintf(buf, "%s- code: %s\n", indent, yamlString(p.Lang))
+			if len(p.Params) > 0 {
+				fmt.Fprintf(buf, "%s  params:\n", indent)
+			}
+			for _, param := range p.Params {
+				values := Map(yamlString, param.Values)
+				fmt.Fprintf(buf, "%s    %s: [%s]\n", indent, yamlString(param.Key), strings.Join(values, ", "))
+			}
+		case MetadataElement:
+			fmt.Fprintf(buf, "%s- metadata: %s\n", indent, yamlString(p.Name))
+		default:
+			fmt.Fprintf(buf, "%s- %s: %s\n", indent, part.TypeName(), yamlString(yamlSummary(part)))
+		}
+	}
+}
+
+// yamlSummary returns the first non-blank line of an element.
+func yamlSummary(part Element) string {
+	lines := proseLines(part)
+	switch p := part.ElementImpl.(type) {
+	case BlockElement:
+		lines = p.Raw
+	case ExampleElement:
+		lines = p.Raw
+	case ExportElement:
+		lines = p.Raw
+	case QuoteElement:
+		lines = p.Raw
+	case CommentElement:
+		lines = p.Raw
+	case DrawerElement:
+		lines = slc(p.Name)
+	}
+	for _, line := range lines {
+		if !spaces.Intersects(line) {
+			return spaces.Trim(line)
+		}
+	}
+	return ""
+}
+
+// yamlString quotes a string, double-quoted YAML strings being a superset of
+// Go quoted strings.
+func yamlString(s string) string {
+	return strconv.Quote(s)
+}
+
+// WordCountOptions configures WordCountWith.
+type WordCountOptions struct {
+	Titles bool // Count the words of section titles.
+}
+
+// WordCount counts the words of the prose, including lists, tables and
+// footnotes but not section titles.
+// Code, metadata and other blocks are never counted.
+func WordCount(matter Elements) int {
+	return WordCountWith(matter, WordCountOptions{})
+}
+
+// WordCountWith is like WordCount, but can count section titles according to
+// the options.
+func WordCountWith(matter Elements, opts WordCountOptions) int {
+	res := 0
+	for _, part := range matter {
+		if _, section := part.AsSection(); section && !opts.Titles {
+			continue
+		}
+		for _, line := range proseLines(part) {
+			res += len(spaces.Fields(line))
+		}
+	}
+	return res
+}
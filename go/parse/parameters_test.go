@@ -0,0 +1,73 @@
+package parse
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// largeParameters makes parameters with keys k0 to k(n-1), each with its key as
+// value.
+func largeParameters(n int) Parameters {
+	res := Parameters{}
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint("k", i)
+		res.Add(key, Values{key})
+	}
+	return res
+}
+
+func TestParametersIndex(t *testing.T) {
+	ps := largeParameters(64)
+	ps.Add("k40", Values{"again"})
+	ps.Remove("k10")
+	index := ps.Index()
+	if len(index) != len(ps) {
+		t.Fatalf("index of %d parameters has %d keys", len(ps), len(index))
+	}
+	for key, i := range index {
+		if vp := ps.Get(key); vp != &ps[i].Values {
+			t.Errorf("%s is indexed at %d but found at %v", key, i, vp)
+		}
+	}
+	if _, found := index["k10"]; found || ps.Has("k10") {
+		t.Errorf("removed key found")
+	}
+}
+
+func BenchmarkParametersGet(b *testing.B) {
+	for _, size := range []int{8, 64, 512} {
+		ps := largeParameters(size)
+		keys := make([]string, size)
+		for i := range keys {
+			keys[i] = fmt.Sprint("k", i)
+		}
+		b.Run(fmt.Sprint("get/", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ps.Get(keys[i%size])
+			}
+		})
+		index := ps.Index()
+		b.Run(fmt.Sprint("index/", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = ps[index[keys[i%size]]]
+			}
+		})
+	}
+}
+
+func TestParametersAddExistingKey(t *testing.T) {
+	ps := Parameters{}
+	ps.Add("k", Values{"a", "b"})
+	ps.Add("other", Values{"x"})
+	ps.Add("k", Values{"c"})
+	expected := Parameters{{"k", Values{"a", "b", "c"}}, {"other", Values{"x"}}}
+	if !reflect.DeepEqual(ps, expected) {
+		t.Errorf("parameters are %v, expected %v", ps, expected)
+	}
+
+	*ps.Get("other") = append(*ps.Get("other"), "y")
+	if vp := ps.Get("other"); !reflect.DeepEqual(*vp, Values{"x", "y"}) {
+		t.Errorf("values modified through Get are %v", *vp)
+	}
+}
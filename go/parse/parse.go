@@ -1,7 +1,13 @@
 package parse
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -12,6 +18,19 @@ import (
 // Element represents a part of a document that has been parsed.
 type Element struct {
 	ElementImpl
+	Span     Span     // Where the element comes from, zero when unknown.
+	Original []string // Lines the element was parsed from, before baking, nil when unknown.
+	Name     string   // Optional, given by the document, see AttachAffiliated.
+
+	// Optional, metadata affecting the element, see AttachAffiliated.
+	Affiliated Parameters
+}
+
+// Span is a range of lines in the source of a document.
+// Lines are 1-based and both ends are inclusive.
+type Span struct {
+	StartLine int
+	EndLine   int
 }
 
 // ElementImpl is the interface that a type must implement to be embeddable into
@@ -32,14 +51,218 @@ func (p Element) Dump() {
 	fmt.Println("}")
 }
 
+// TypeName returns a stable name for the type of the implementation, e.g. code
+// for a CodeElement, meant for serialisation and messages.
+// Implementations defined outside of this package are named after their type,
+// lowercased and without the Element suffix.
+func (p Element) TypeName() string {
+	switch p.ElementImpl.(type) {
+	case ProseElement:
+		return "prose"
+	case SpaceElement:
+		return "space"
+	case MarkupElement:
+		return "markup"
+	case ExampleElement:
+		return "example"
+	case CommentElement:
+		return "comment"
+	case QuoteElement:
+		return "quote"
+	case BlockElement:
+		return "block"
+	case ExportElement:
+		return "export"
+	case CodeElement:
+		return "code"
+	case MetadataElement:
+		return "metadata"
+	case DrawerElement:
+		return "drawer"
+	case TableElement:
+		return "table"
+	case ListElement:
+		return "list"
+	case FootnoteElement:
+		return "footnote"
+	case SectionElement:
+		return "section"
+	}
+	kind := strings.TrimSuffix(fmt.Sprintf("%T", p.ElementImpl), "Element")
+	return strings.ToLower(kind[strings.LastIndexByte(kind, '.')+1:])
+}
+
+// MarshalJSON encodes the Element as an object holding its type name as Kind,
+// its span and its implementation.
+func (p Element) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind  string
+		Span  Span
+		Value ElementImpl
+	}{p.TypeName(), p.Span, p.ElementImpl})
+}
+
 // void returns true if this Element holds no implementation.
 func (p Element) void() bool {
 	return p.ElementImpl == nil
 }
 
+// AsCode returns the code held by this Element, if any.
+func (p Element) AsCode() (CodeElement, bool) {
+	res, ok := p.ElementImpl.(CodeElement)
+	return res, ok
+}
+
+// AsProse returns the prose held by this Element, if any.
+func (p Element) AsProse() (ProseElement, bool) {
+	res, ok := p.ElementImpl.(ProseElement)
+	return res, ok
+}
+
+// AsSection returns the section held by this Element, if any.
+func (p Element) AsSection() (SectionElement, bool) {
+	res, ok := p.ElementImpl.(SectionElement)
+	return res, ok
+}
+
+// AsMetadata returns the metadata held by this Element, if any.
+func (p Element) AsMetadata() (MetadataElement, bool) {
+	res, ok := p.ElementImpl.(MetadataElement)
+	return res, ok
+}
+
+// The following predicates tell the kind of an Element, so that method values
+// like Element.IsCode can be given to Elements.Filter.
+
+func (p Element) IsCode() bool     { return p.TypeName() == "code" }
+func (p Element) IsProse() bool    { return p.TypeName() == "prose" }
+func (p Element) IsSection() bool  { return p.TypeName() == "section" }
+func (p Element) IsSpace() bool    { return p.TypeName() == "space" }
+func (p Element) IsMetadata() bool { return p.TypeName() == "metadata" }
+
+// IsBlock tells whether the Element is a block other than code, that is a
+// generic block, an example, a quote or an export.
+func (p Element) IsBlock() bool {
+	switch p.ElementImpl.(type) {
+	case BlockElement, ExampleElement, QuoteElement, ExportElement:
+		return true
+	}
+	return false
+}
+
 // Elements is a sequence of parsed Element.
 type Elements []Element
 
+// Filter returns the Elements satisfying pred, without modifying the receiver.
+func (ps Elements) Filter(pred Pred[Element]) Elements {
+	return Filter(pred, ps)
+}
+
+// MapElements returns the Elements transformed by fn, without modifying the
+// receiver.
+func (ps Elements) MapElements(fn func(Element) Element) Elements {
+	return Map(fn, ps)
+}
+
+// Walk calls fn on each Element in order, stopping at the first error, which is
+// returned.
+func (ps Elements) Walk(fn func(i int, p Element) error) error {
+	for i, p := range ps {
+		if err := fn(i, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Metadata collects the metadata affecting the whole document, like Org's
+// #+TITLE, in document order.
+// The values of a metadata are its data, fused verbatim, followed by its
+// verbatim content, and the values of repeated metadata are merged under the first occurrence.
+// The receiver is not modified.
+func (ps Elements) Metadata() Parameters {
+	res := Parameters{}
+	for _, part := range ps {
+		meta, ok := part.AsMetadata()
+		if !ok || meta.Scope != ScopeDocument || meta.Name == "" {
+			continue
+		}
+		values := Values{}
+		if !meta.Data.Empty() {
+			values = append(values, meta.Data.FuseVerbatim())
+		}
+		res.Add(meta.Name, append(values, meta.RawValue...))
+	}
+	return res
+}
+
+// codeLangAliases associates alternative names of languages with their
+// canonical name, see CanonicalLang.
+var codeLangAliases = map[string]string{
+	"golang":       "go",
+	"c++":          "cpp",
+	"cxx":          "cpp",
+	"py":           "python",
+	"python3":      "python",
+	"js":           "javascript",
+	"node":         "javascript",
+	"ts":           "typescript",
+	"rs":           "rust",
+	"rb":           "ruby",
+	"hs":           "haskell",
+	"elisp":        "emacs-lisp",
+	"yml":          "yaml",
+	"md":           "markdown",
+	"sh":           "shell",
+	"shell-script": "shell",
+}
+
+// CanonicalLang returns the canonical name of a language, i.e. its lowercased
+// name, unless it is an alias, like golang for go.
+func CanonicalLang(id string) string {
+	id = strings.ToLower(id)
+	if canonical, found := codeLangAliases[id]; found {
+		return canonical
+	}
+	return id
+}
+
+// RegisterLangAlias makes alias an alternative name of the canonical language,
+// both being compared case-insensitively.
+// The aliases are global, so they should be registered before parsing starts.
+func RegisterLangAlias(alias, canonical string) {
+	codeLangAliases[strings.ToLower(alias)] = CanonicalLang(canonical)
+}
+
+// NormalizeLangs replaces the language of code with its canonical name, see
+// CanonicalLang.
+// The receiver is not modified.
+func (ps Elements) NormalizeLangs() Elements {
+	res := make(Elements, len(ps))
+	for i, part := range ps {
+		res[i] = part
+		if code, ok := part.AsCode(); ok {
+			code.Lang = CanonicalLang(code.Lang)
+			res[i].ElementImpl = code
+		}
+	}
+	return res
+}
+
+// CodeBlocks returns the code of the given language, all of it when lang is
+// empty.
+// Languages are compared by canonical name, see CanonicalLang.
+func (ps Elements) CodeBlocks(lang string) []CodeElement {
+	res := []CodeElement{}
+	for _, part := range ps {
+		code, ok := part.AsCode()
+		if ok && (lang == "" || CanonicalLang(code.Lang) == CanonicalLang(lang)) {
+			res = append(res, code)
+		}
+	}
+	return res
+}
+
 // Dump dumps all the contained Elements to stdout for debugging purposes.
 func (ps Elements) Dump() {
 	for _, p := range ps {
@@ -47,6 +270,34 @@ func (ps Elements) Dump() {
 	}
 }
 
+// proseLines returns the human-readable lines of an element, in which inline
+// markup like footnote references or links can appear.
+func proseLines(e Element) []string {
+	switch p := e.ElementImpl.(type) {
+	case ProseElement:
+		return p.Raw
+	case MarkupElement:
+		return p.Lines()
+	case SectionElement:
+		return slc(p.Title)
+	case FootnoteElement:
+		return p.Body
+	case ListElement:
+		res := slice[string]{}
+		for _, item := range p.Items {
+			res.Add(item.Lines...)
+		}
+		return res
+	case TableElement:
+		res := slice[string]{}
+		for _, row := range p.Rows {
+			res.Add(row...)
+		}
+		return res
+	}
+	return nil
+}
+
 // Parameters represents metadata attached to a file or a element.
 // It is implemented as key-value pairs and not as a map in order to maintain
 // the order.
@@ -63,20 +314,74 @@ type Parameter struct {
 // is a simple and somewhat universal format.
 type Values []string
 
+// One returns the value, which must be unique.
+func (vs Values) One() (string, error) {
+	if len(vs) != 1 {
+		return "", fmt.Errorf("expected exactly one value, got %d", len(vs))
+	}
+	return vs[0], nil
+}
+
+// Int returns the value, which must be a unique integer.
+func (vs Values) Int() (int, error) {
+	v, err := vs.One()
+	if err != nil {
+		return 0, err
+	}
+	res, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("`%s` is not an integer", v)
+	}
+	return res, nil
+}
+
+// Bool returns the value, which must be a unique boolean.
+// Following Org, yes, true and t are true whereas no, false and nil are false.
+func (vs Values) Bool() (bool, error) {
+	v, err := vs.One()
+	if err != nil {
+		return false, err
+	}
+	switch v {
+	case "yes", "true", "t":
+		return true, nil
+	case "no", "false", "nil":
+		return false, nil
+	}
+	return false, fmt.Errorf("`%s` is not a boolean", v)
+}
+
 // Empty returns true if there are no parameters.
 func (ps Parameters) Empty() bool {
 	return len(ps) == 0
 }
 
+// Index returns the position of each key in the parameters.
+// Get is a linear scan, so building the index once is preferable when looking
+// up many keys in a large set of parameters.
+// The index is not updated when the parameters are modified.
+func (ps Parameters) Index() map[string]int {
+	res := make(map[string]int, len(ps))
+	for i, p := range ps {
+		if _, found := res[p.Key]; !found { // Get returns the first one.
+			res[p.Key] = i
+		}
+	}
+	return res
+}
+
 // Has returns true if the given key is contained in the parameters.
 func (ps Parameters) Has(key string) bool {
 	return ps.Get(key) != nil
 }
 
+// Get returns the values of the given key, nil if it is absent.
+// The values are stored in the parameters, so that modifying them through the
+// pointer modifies the parameters.
 func (ps *Parameters) Get(key string) *Values {
-	for _, p := range *ps {
-		if p.Key == key {
-			return &p.Values
+	for i := range *ps {
+		if (*ps)[i].Key == key {
+			return &(*ps)[i].Values
 		}
 	}
 	return nil
@@ -92,15 +397,97 @@ func (ps *Parameters) Add(key string, values Values) {
 	}
 }
 
+// Set replaces the values of the given key, creating it if necessary.
+func (ps *Parameters) Set(key string, values Values) {
+	vp := ps.Get(key)
+	if vp == nil {
+		*ps = append(*ps, Parameter{key, values})
+	} else {
+		*vp = values
+	}
+}
+
+// Remove removes the given key, returning true if it was present.
+// The order of the other parameters is preserved.
+func (ps *Parameters) Remove(key string) bool {
+	for i, p := range *ps {
+		if p.Key == key {
+			*ps = append((*ps)[:i], (*ps)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ToMap converts the parameters to a map from keys to values.
+// The order of the parameters is lost.
+func (ps Parameters) ToMap() map[string][]string {
+	res := make(map[string][]string, len(ps))
+	for _, p := range ps {
+		res[p.Key] = append([]string{}, p.Values...)
+	}
+	return res
+}
+
+// ParametersFromMap converts a map from keys to values to parameters, sorted by
+// key.
+func ParametersFromMap(m map[string][]string) Parameters {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	res := make(Parameters, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, Parameter{key, append(Values{}, m[key]...)})
+	}
+	return res
+}
+
 // FuseToNoweb fuses (aka serialises) parameters into noweb arguments.
+// Values are quoted when necessary.
+// Positional values, stored under the empty key, are fused bare and first,
+// since they would otherwise be parsed back as values of the preceding key.
+// Whether a value must be quoted can depend on what follows it, so they are
+// fused from right to left.
 func (ps Parameters) FuseToNoweb() string {
 	acc := []string{}
+	values := map[int]bool{} // Indices of the values in acc, as opposed to keys.
+	if vp := ps.Get(""); vp != nil {
+		for _, value := range *vp {
+			values[len(acc)] = true
+			acc = append(acc, value)
+		}
+	}
 	for _, p := range ps {
-		if p.Key != "" {
-			acc = append(acc, ":"+p.Key)
+		if p.Key == "" {
+			continue
 		}
-		if len(p.Values) > 0 {
-			acc = append(acc, p.Values...)
+		acc = append(acc, ":"+p.Key)
+		for _, value := range p.Values {
+			values[len(acc)] = true
+			acc = append(acc, value)
+		}
+	}
+	for i := len(acc) - 1; i >= 0; i-- {
+		if values[i] {
+			acc[i] = fuseNowebValue(acc[i], strings.Join(acc[i+1:], " "))
+		}
+	}
+	return strings.Join(acc, " ")
+}
+
+// FuseVerbatim is like FuseToNoweb, but never quotes values.
+// This is how values that are not noweb arguments are written, like the YAML
+// values of a Markdown front matter, which are stored as is.
+func (ps Parameters) FuseVerbatim() string {
+	acc := []string{}
+	if vp := ps.Get(""); vp != nil {
+		acc = append(acc, *vp...)
+	}
+	for _, p := range ps {
+		if p.Key != "" {
+			acc = append(append(acc, ":"+p.Key), p.Values...)
 		}
 	}
 	return strings.Join(acc, " ")
@@ -124,9 +511,44 @@ type prose struct{}
 
 // SpaceElement represents whitespace (space, newline and tab), content that
 // typically holds no particular meaning within a document.
+// Its lines are kept verbatim, so that the number of blank lines between
+// elements round-trips, which matters in formats like Markdown.
 type SpaceElement = RawElement[space]
 type space struct{}
 
+// ExampleElement represents verbatim content, meant to be displayed as is but
+// never tangled.
+type ExampleElement struct {
+	Raw   []string
+	Style string // Syntax of the example, like block, fixed or indented.
+}
+
+func (e ExampleElement) Repr() []string {
+	return *pslc("style=" + e.Style).Add(e.Raw...)
+}
+
+// CommentElement represents comments, content that is not meant to be exported.
+// Comments are kept verbatim, delimiters included.
+type CommentElement struct {
+	Raw   []string
+	Style string // Syntax of the comment, like line or html.
+}
+
+func (c CommentElement) Repr() []string {
+	return *pslc("style=" + c.Style).Add(c.Raw...)
+}
+
+// QuoteElement represents quoted content, which can be nested in other quotes.
+type QuoteElement struct {
+	Raw    []string
+	Level  int    // Nesting level, starting at 1.
+	Marker string // Markers of the quote as written, like "> >", in languages having them.
+}
+
+func (q QuoteElement) Repr() []string {
+	return *pslc("level=" + fmt.Sprint(q.Level)).Add(q.Raw...)
+}
+
 // BlockElement represents a special block qualified by its type.
 type BlockElement struct {
 	Raw  []string
@@ -137,45 +559,327 @@ func (b BlockElement) Repr() []string {
 	return *pslc("type=" + b.Type).Add(b.Raw...)
 }
 
+// ExportElement represents content meant for a single export backend, like
+// HTML or LaTeX, and kept verbatim.
+type ExportElement struct {
+	Raw     []string
+	Backend string // Name of the backend, like html.
+}
+
+func (e ExportElement) Repr() []string {
+	return *pslc("backend=" + e.Backend).Add(e.Raw...)
+}
+
 // CodeElement represents code, content meant for machine consumption.
 type CodeElement struct {
 	Raw    []string   // Code.
 	Lang   string     // Identifier of the language.
 	Params Parameters // Parameters of the code block.
+	Indent string     // Indentation of the code block, in languages allowing it.
+	Style  string     // Syntax of the code block, like the directive of a RST code block.
+
+	// Lines of the output of the code, nil when there is none.
+	// Only filled in languages where the output follows the code, like Org.
+	Results []string
 }
 
 func (c CodeElement) Repr() []string {
-	return *pslc("lang="+c.Lang, "Params="+c.Params.FuseToNoweb()).Add(c.Raw...)
+	res := pslc("lang="+c.Lang, "Params="+c.Params.FuseToNoweb())
+	if c.Indent != "" {
+		res.Add("indent=" + strconv.Quote(c.Indent))
+	}
+	if c.Style != "" {
+		res.Add("style=" + c.Style)
+	}
+	res.Add(c.Raw...)
+	if c.Results != nil {
+		res.Add("Results:").Add(c.Results...)
+	}
+	return *res
+}
+
+// shebangRe matches a shebang, capturing the name of the interpreter without
+// its version, possibly run by env.
+var shebangRe = re(`^#!\s*\S*/(?:env\s+(?:-\S+\s+)*)?([A-Za-z][A-Za-z-]*?)[0-9.]*(?:\s|$)`)
+
+// langPatterns associates the first lines typical of some languages with
+// them, see InferLang.
+var langPatterns = []struct {
+	pattern regex
+	lang    string
+}{
+	{re(`^package\s+\w+\s*$`), "go"},
+	{re(`^<\?php`), "php"},
+	{re(`^<\?xml\s`), "xml"},
+	{re(`^(?i)<!DOCTYPE\s+html|^<html[\s>]`), "html"},
+	{re(`^#include\s*[<"]`), "c"},
+	{re(`^\(defun\s|^;;;`), "emacs-lisp"},
+}
+
+// InferLang guesses the language of the code from its shebang or from its
+// first non-blank line, returning an empty string when inconclusive.
+// The version of interpreters is dropped, e.g. python3 gives python.
+func (c CodeElement) InferLang() string {
+	for _, line := range c.Raw {
+		if spaces.Intersects(line) {
+			continue
+		}
+		if groups := shebangRe.Groups(line); groups != nil {
+			return groups[1]
+		}
+		for _, candidate := range langPatterns {
+			if candidate.pattern.Match(line) {
+				return candidate.lang
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// InferLangs sets the language of the code lacking one, when it can be
+// inferred, see CodeElement.InferLang.
+// The receiver is not modified.
+func (ps Elements) InferLangs() Elements {
+	res := make(Elements, len(ps))
+	for i, part := range ps {
+		res[i] = part
+		if code, ok := part.AsCode(); ok && code.Lang == "" {
+			code.Lang = code.InferLang()
+			res[i].ElementImpl = code
+		}
+	}
+	return res
+}
+
+// Vars returns the variables assigned by the var parameters, like
+// `:var x=1, s="a b"`, by name.
+// Values are kept as written, so that quoted strings can be told apart from
+// numbers and references to other blocks.
+func (c CodeElement) Vars() (map[string]string, error) {
+	res := map[string]string{}
+	values := c.Params.Get("var")
+	if values == nil {
+		return res, nil
+	}
+	source := strings.Join(*values, " ") // Quoted values may have been split.
+	separators := str(" \t,")
+	for {
+		start := separators.Skim(source)
+		if start == -1 {
+			return res, nil
+		}
+		source = source[start:]
+		name, rest, found := strings.Cut(source, "=")
+		if !found || name == "" || separators.First(name) != -1 {
+			return nil, fmt.Errorf("invalid variable assignment `%s`", source)
+		}
+		end := separators.First(rest)
+		if str(`"`).IsPrefix(rest) {
+			end = strings.IndexByte(rest[1:], '"') + 2
+			if end == 1 {
+				return nil, fmt.Errorf("unterminated string in variable assignment `%s`", source)
+			}
+		}
+		if end == -1 {
+			end = len(rest)
+		}
+		res[name] = rest[:end]
+		source = rest[end:]
+	}
+}
+
+// lineCommentPrefixes associates canonical languages with the prefix of their
+// line comments, see StripComments.
+var lineCommentPrefixes = map[string]str{
+	"go": "//", "c": "//", "cpp": "//", "java": "//", "javascript": "//",
+	"typescript": "//", "rust": "//", "kotlin": "//", "swift": "//",
+	"python": "#", "shell": "#", "bash": "#", "zsh": "#", "ruby": "#", "perl": "#",
+	"r": "#", "yaml": "#", "toml": "#", "makefile": "#",
+	"haskell": "--", "lua": "--", "sql": "--",
+	"emacs-lisp": ";", "lisp": ";", "scheme": ";", "clojure": ";",
+}
+
+// StripComments returns a copy of the code without the lines holding only a
+// comment, when the language is known.
+// This is naive line-prefix stripping: comments following code and markers
+// within string literals are left untouched, and block comments are not
+// supported.
+// A shebang on the first line is kept.
+func StripComments(code CodeElement) CodeElement {
+	prefix, known := lineCommentPrefixes[CanonicalLang(code.Lang)]
+	if !known {
+		return code
+	}
+	raw := []string{}
+	for i, line := range code.Raw {
+		shebang := i == 0 && str("#!").IsPrefix(line)
+		if shebang || !prefix.IsPrefix(strings.TrimLeft(line, " \t")) {
+			raw = append(raw, line)
+		}
+	}
+	code.Raw = raw
+	return code
 }
 
 type MetadataScope int
 
+func (s MetadataScope) String() string {
+	switch s {
+	case ScopeDocument:
+		return "document"
+	case ScopeSubtree:
+		return "subtree"
+	case ScopeElement:
+		return "element"
+	}
+	return "invalid"
+}
+
 const (
 	ScopeInvalid  MetadataScope = iota // Defined so that zero-constructed values are not accidentaly global.
 	ScopeDocument                      // Affects the whole document.
 	ScopeSubtree                       // Affects the current subtree (i.e. the section and its subsections).
+	ScopeElement                       // Affects the element that follows.
 )
 
 // MetadataElement holds metadata about the document.
 type MetadataElement struct {
-	Name  string
-	Data  Parameters
-	Scope MetadataScope
+	Name     string
+	Data     Parameters
+	Scope    MetadataScope
+	RawValue []string // Verbatim content that is not parsed into Data.
 }
 
 func (m MetadataElement) Repr() []string {
-	return slc(m.Name + "=" + m.Data.FuseToNoweb())
+	repr := m.Name + "=" + m.Data.FuseToNoweb()
+	if m.Scope != ScopeDocument {
+		repr += ", scope=" + m.Scope.String()
+	}
+	return *pslc(repr).Add(m.RawValue...)
+}
+
+// DrawerElement represents a drawer, holding properties about the section that
+// precedes it.
+type DrawerElement struct {
+	Name  string
+	Props Parameters
+
+	// Lines of the drawer as written, delimiters included, fused as is as long
+	// as they hold Props.
+	Raw []string
+}
+
+func (d DrawerElement) Repr() []string {
+	return slc("name=" + d.Name + ", props=" + d.Props.FuseToNoweb())
+}
+
+// TableElement represents a table, whose first row is the header.
+type TableElement struct {
+	Rows  [][]string // Cells, unescaped.
+	Align []string   // Alignment of each column, left, center, right or empty.
+}
+
+func (t TableElement) Repr() []string {
+	res := slc("align=" + strings.Join(t.Align, ","))
+	for _, row := range t.Rows {
+		res.Add(strings.Join(row, " | "))
+	}
+	return res
+}
+
+// ListElement represents a list, whose items can be nested.
+type ListElement struct {
+	Items   []ListItem
+	Ordered bool // Whether the first level of the list is numbered.
+	Indent  int  // Indentation of the first level.
+}
+
+// ListItem is an item of a list.
+type ListItem struct {
+	Lines   []string // Text of the item, without bullet, checkbox nor indentation.
+	Depth   int      // Nesting depth, starting at 0.
+	Bullet  string   // Bullet of the item, like - or 1.
+	Checked *bool    // State of the checkbox, nil when there is none.
+	Partial bool     // Whether the checkbox is partially checked, Checked is then false.
+
+	// Term of description items, like `term :: definition`, the definition
+	// being in Lines.
+	// TermSep is the separator with its original spacing, like " :: ".
+	Term, TermSep string
+}
+
+// Progress counts the checked items and the items with a checkbox.
+func (l ListElement) Progress() (done, total int) {
+	for _, item := range l.Items {
+		if item.Checked == nil {
+			continue
+		}
+		total++
+		if *item.Checked {
+			done++
+		}
+	}
+	return
+}
+
+func (l ListElement) Repr() []string {
+	res := slc("ordered=" + fmt.Sprint(l.Ordered))
+	if l.Indent != 0 {
+		res[0] += ", indent=" + fmt.Sprint(l.Indent)
+	}
+	for _, item := range l.Items {
+		prefix := strings.Repeat("  ", item.Depth) + item.Bullet + " "
+		if item.Checked != nil {
+			prefix += fmt.Sprintf("checked=%t,partial=%t ", *item.Checked, item.Partial)
+		}
+		if item.TermSep != "" {
+			prefix += "term=" + item.Term + " :: "
+		}
+		for _, line := range item.Lines {
+			res.Add(prefix + line)
+			prefix = strings.Repeat(" ", len(prefix))
+		}
+	}
+	return res
+}
+
+// FootnoteElement represents the definition of a footnote.
+type FootnoteElement struct {
+	Label string
+	Body  []string
+}
+
+func (f FootnoteElement) Repr() []string {
+	return *pslc("label=" + f.Label).Add(f.Body...)
 }
 
 // SectionElement represents a section marker, symbolising a new branch of the
 // document tree.
 type SectionElement struct {
-	Title string
-	Level int
+	Title    string
+	Level    int
+	Todo     string     // Keyword describing the state of the section, like TODO.
+	Priority string     // Priority of the section, like A.
+	Tags     []string   // Tags of the section, without colons.
+	Params   Parameters // Format-specific details, needed to reconstruct the marker.
 }
 
 func (m SectionElement) Repr() []string {
-	return slc("level=" + fmt.Sprint(m.Level) + ", title=" + m.Title)
+	repr := "level=" + fmt.Sprint(m.Level) + ", title=" + m.Title
+	if m.Todo != "" {
+		repr += ", todo=" + m.Todo
+	}
+	if m.Priority != "" {
+		repr += ", priority=" + m.Priority
+	}
+	if len(m.Tags) > 0 {
+		repr += ", tags=" + strings.Join(m.Tags, ":")
+	}
+	if !m.Params.Empty() {
+		repr += ", params=" + m.Params.FuseToNoweb()
+	}
+	return slc(repr)
 }
 
 ////////////////////////
@@ -186,60 +890,292 @@ type Taker func([]string) int
 type Baker func(string) string
 type Maker func([]string) ElementImpl
 
+// ErrorTaker is a Taker able to explain why it took nothing.
+// The error is only meaningful when nothing is taken.
+type ErrorTaker func([]string) (int, error)
+
 // Rule is the smallest parsing entity.
 // It defines how to produce a given element from raw text.
 type Rule struct {
-	Take Taker // How many lines to take.
-	Bake Baker // How to transform a single line.
-	Make Maker // How to make a element with transformed lines.
-	// IDEA: MonoTake, MonoMake for more convenient definition of one line elements.
-	// IDEA: ErrorTake, ErrorMake to get explanations on why parsing failed.
-	//       Could provide useful error diagnostics.
+	Name      string     // Optional, identifies the rule when tracing.
+	Take      Taker      // How many lines to take.
+	ErrorTake ErrorTaker // Optional, used instead of Take to explain failures.
+	Bake      Baker      // How to transform a single line, lines are kept when nil.
+	Make      Maker      // How to make a element with transformed lines.
+	// IDEA: ErrorMake to get explanations on why making failed.
 }
 
 // Emit tries to parse the given lines, returning the lines that were not taken
 // as well as the Element that was made.
-// When the lines are not parsed, a void Element is emitted.
-// The error is always nil but it will be used at some point as a mechanism to
-// provide error diagnostics.
+// When the lines are not parsed, a void Element is emitted, along with an
+// error diagnosing why when the rule has an ErrorTaker.
 func (a Rule) Emit(lines []string) ([]string, Element, error) {
-	take := a.Take(lines)
-	if take == 0 {
+	if len(lines) == 0 { // Nothing to make an element from.
 		return lines, Element{}, nil
 	}
-	return lines[take:], Element{a.Make(Map(a.Bake, lines[:take]))}, nil
+	var take int
+	var err error
+	if a.ErrorTake != nil {
+		take, err = a.ErrorTake(lines)
+	} else {
+		take = a.Take(lines)
+	}
+	if take == 0 {
+		return lines, Element{}, err
+	}
+	taken := lines[:take:take]
+	return lines[take:], Element{ElementImpl: a.Make(a.bake(taken)), Original: taken}, nil
+}
+
+// noBkPointer identifies NoBk, since functions cannot be compared.
+var noBkPointer = reflect.ValueOf(NoBk).Pointer()
+
+// bake transforms the taken lines.
+// Lines baked with NoBk are not copied, their capacity is only restricted so
+// that appending to them does not overwrite the lines that follow.
+func (a Rule) bake(taken []string) []string {
+	if a.Bake == nil || reflect.ValueOf(a.Bake).Pointer() == noBkPointer {
+		return taken[:len(taken):len(taken)]
+	}
+	return Map(a.Bake, taken)
 }
 
 // Rules represents a sequence of Rule defining all the logic necessary to parse
 // a literate document.
 type Rules []Rule
 
+// WithBake returns a copy of the Rules where bk is applied after the Baker of
+// each Rule, e.g. to strip trailing whitespace everywhere with TrimRightBk.
+func (m Rules) WithBake(bk Baker) Rules {
+	res := make(Rules, len(m))
+	for i, rule := range m {
+		previous := rule.Bake
+		if previous == nil || reflect.ValueOf(previous).Pointer() == noBkPointer {
+			rule.Bake = bk
+		} else {
+			rule.Bake = PipeBake(previous, bk)
+		}
+		res[i] = rule
+	}
+	return res
+}
+
 // Parse tries to parse the given lines with its Rules.
 // If several of its Rules can parse a given line, the first one is chosen,
 // hence to correctly parse a document, it is primordial to pay attention to the
 // order of the Rules.
+// Each element is given the span of the lines it was made from.
+// When no rule can parse a line, the first diagnostic given by a rule is
+// included in the error.
 func (m Rules) Parse(lines []string) (Elements, error) {
+	return m.parseFrom(lines, 1)
+}
+
+// ParseLenient is like Parse, but instead of failing on a line that no rule can
+// parse, makes it a prose element and carries on.
+// The errors that Parse would have returned are returned along with the
+// elements, in document order.
+func (m Rules) ParseLenient(lines []string) (Elements, []error) {
 	res := Elements{}
+	errs := []error{}
+	number := 1
 	for len(lines) > 0 {
-		var emitted Element
-		var err error
-		for _, rule := range m {
-			lines, emitted, err = rule.Emit(lines)
-			if err != nil {
-				return nil, err
-			}
-			if !emitted.void() { // Managed to find an rule parsing the lines.
-				res = append(res, emitted)
-				break
+		emitted, take, _, err := m.emit(lines, number)
+		if err != nil {
+			errs = append(errs, err)
+			emitted = Element{
+				ElementImpl: ProseElement{Raw: lines[:1:1]},
+				Span:        Span{StartLine: number, EndLine: number},
+				Original:    lines[:1:1],
 			}
+			take = 1
 		}
-		if emitted.void() {
-			return nil, fmt.Errorf("could not parse line `%s`", lines[0])
+		res = append(res, emitted)
+		lines = lines[take:]
+		number += take
+	}
+	return res, errs
+}
+
+// parseFrom parses lines starting at the given line number, which is used to
+// locate the elements and to report errors.
+func (m Rules) parseFrom(lines []string, number int) (Elements, error) {
+	res := Elements{}
+	for len(lines) > 0 {
+		emitted, take, _, err := m.emit(lines, number)
+		if err != nil {
+			return nil, err
 		}
+		res = append(res, emitted)
+		lines = lines[take:]
+		number += take
 	}
 	return res, nil
 }
 
+// TraceEntry records which rule made an element, see Rules.ParseTrace.
+type TraceEntry struct {
+	Rule  string // Name of the rule, empty when it has none.
+	Index int    // Index of the rule in the Rules.
+	Span  Span   // Lines the element was made from.
+}
+
+// String describes the entry, like "lines 3-5: prose", unnamed rules being
+// designated by their index, like "rule #2".
+func (t TraceEntry) String() string {
+	rule := t.Rule
+	if rule == "" {
+		rule = fmt.Sprintf("rule #%d", t.Index)
+	}
+	return fmt.Sprintf("lines %d-%d: %s", t.Span.StartLine, t.Span.EndLine, rule)
+}
+
+// ParseTrace is like Parse, but also records which rule made each element, in
+// order to debug the ordering of the Rules.
+// On failure, the elements parsed and the entries recorded before the faulty
+// line are returned along with the error.
+func (m Rules) ParseTrace(lines []string) (Elements, []TraceEntry, error) {
+	res := Elements{}
+	trace := []TraceEntry{}
+	number := 1
+	for len(lines) > 0 {
+		emitted, take, index, err := m.emit(lines, number)
+		if err != nil {
+			return res, trace, err
+		}
+		res = append(res, emitted)
+		trace = append(trace, TraceEntry{Rule: m[index].Name, Index: index, Span: emitted.Span})
+		lines = lines[take:]
+		number += take
+	}
+	return res, trace, nil
+}
+
+// emit makes an element from the first lines with the first rule able to,
+// returning the element, the number of lines it was made from and the index of
+// the rule.
+func (m Rules) emit(lines []string, number int) (Element, int, int, error) {
+	var diagnostic error
+	for i, rule := range m {
+		rest, emitted, err := rule.Emit(lines)
+		if !emitted.void() { // Managed to find an rule parsing the lines.
+			take := len(lines) - len(rest)
+			emitted.Span = Span{StartLine: number, EndLine: number + take - 1}
+			return emitted, take, i, nil
+		}
+		if diagnostic == nil {
+			diagnostic = err
+		}
+	}
+	return Element{}, 0, -1, ParseError{Line: number, Content: lines[0], Err: diagnostic}
+}
+
+// readerLookahead is the number of lines that must follow an element parsed by
+// ParseReader for it to be considered complete.
+// Takers looking further ahead than that are not supported by ParseReader.
+const readerLookahead = 8
+
+// ParseReader is like Parse, but reads the lines as they are needed instead of
+// requiring the whole document.
+// The lines are buffered until the element they start is complete, which means
+// that the whole content of a block has to fit in memory, but not the whole
+// document.
+// The lines are split like with strings.Split, so that a final newline starts
+// an empty line and is therefore reproduced by fusing.
+func (m Rules) ParseReader(r io.Reader) (Elements, error) {
+	reader := bufio.NewReader(r)
+	lines := []string{}
+	eof := false
+	fill := func(size int) error { // Reads lines until there are size of them.
+		for !eof && len(lines) < size {
+			line, err := reader.ReadString('\n')
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+		return nil
+	}
+
+	res := Elements{}
+	number := 1
+	size := 2 * readerLookahead
+	for {
+		if err := fill(size); err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			return res, nil
+		}
+
+		emitted, take, _, err := m.emit(lines, number)
+		if !eof && (err != nil || !settled(lines[take:])) {
+			size = 2 * len(lines) // More lines could complete the element.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, emitted)
+		lines = lines[take:]
+		number += take
+		size = 2 * readerLookahead
+	}
+}
+
+// settled returns true when the lines following an element are enough to be
+// sure that more lines would not change the element, i.e. when there are enough
+// of them and one of them is not blank.
+func settled(following []string) bool {
+	if len(following) < readerLookahead {
+		return false
+	}
+	for _, line := range following {
+		if !spaces.Intersects(line) {
+			return true
+		}
+	}
+	return false
+}
+
+////////////
+// Errors //
+////////////
+
+// ParseError is returned when no rule can parse a line.
+type ParseError struct {
+	Line    int
+	Content string
+	Err     error // Diagnostic given by a rule, nil when there is none.
+}
+
+func (e ParseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("could not parse line %d: `%s`: %v", e.Line, e.Content, e.Err)
+	}
+	return fmt.Sprintf("could not parse line %d: `%s`", e.Line, e.Content)
+}
+
+// Unwrap returns the diagnostic of the error.
+func (e ParseError) Unwrap() error { return e.Err }
+
+// FuseError is returned when a fuser does not know how to fuse an element.
+type FuseError struct {
+	Lang string // Name of the fuser.
+	Type string // What could not be fused, usually the TypeName of an element.
+}
+
+func (e FuseError) Error() string {
+	return fmt.Sprintf("no %s fuser for %s", e.Lang, e.Type)
+}
+
+// fuseError builds a FuseError for an element.
+func fuseError(lang string, part Element) FuseError {
+	return FuseError{Lang: lang, Type: part.TypeName()}
+}
+
 //////////////////////
 // Taker generators //
 //////////////////////
@@ -262,26 +1198,82 @@ func GreedyTake(pred Pred[string]) Taker {
 // predicate is satisfied, none otherwise.
 func FirstTake(pred Pred[string]) Taker {
 	return func(lines []string) int {
-		if pred(lines[0]) {
+		if len(lines) > 0 && pred(lines[0]) {
 			return 1
 		}
 		return 0
 	}
 }
 
+// MonoTake builds a Taker function for one line elements, taking the line when
+// the predicate is satisfied.
+// It is the same as FirstTake, but conveys the intent of the rule.
+func MonoTake(pred Pred[string]) Taker { return FirstTake(pred) }
+
+// CountTake builds a Taker function that will take n lines, or all of them when
+// there are less.
+// It is meant to be combined with lookahead, since it takes lines
+// unconditionally.
+func CountTake(n int) Taker {
+	return func(lines []string) int {
+		if n <= 0 {
+			return 0
+		}
+		if len(lines) < n {
+			return len(lines)
+		}
+		return n
+	}
+}
+
 // BetweenTake builds a Taker function that will take all the lines between its
 // first and last predicates, first and last line included.
 func BetweenTake(first, last Pred[string]) Taker {
+	take := BetweenErrorTake(first, last)
 	return func(lines []string) int {
-		if !first(lines[0]) {
-			return 0
+		n, _ := take(lines)
+		return n
+	}
+}
+
+// BetweenErrorTake is like BetweenTake, but diagnoses unterminated blocks.
+func BetweenErrorTake(first, last Pred[string]) ErrorTaker {
+	return func(lines []string) (int, error) {
+		if len(lines) == 0 || !first(lines[0]) {
+			return 0, nil
 		}
 		for i, line := range lines[1:] {
 			if last(line) {
-				return i + 2 // Include begin and end lines.
+				return i + 2, nil // Include begin and end lines.
 			}
 		}
-		return 0 // TODO: Make taker return an error to signal that parsing went wrong?
+		return 0, fmt.Errorf("unterminated block: found `%s` but no line closing it", lines[0])
+	}
+}
+
+// RegexpBetweenTake is like BetweenTake, but with the delimiting lines matched
+// by regexps, whose groups can be given to a GroupMaker with ReBetweenMake.
+func RegexpBetweenTake(begin, end regex) Taker {
+	return BetweenTake(begin.Match, end.Match)
+}
+
+// RegexpBetweenErrorTake is like RegexpBetweenTake, but diagnoses unterminated
+// blocks.
+func RegexpBetweenErrorTake(begin, end regex) ErrorTaker {
+	return BetweenErrorTake(begin.Match, end.Match)
+}
+
+// IndentTake builds a Taker function that will take lines indented by at least
+// minIndent spaces or tabs, starting with a non-blank one.
+// Like with TrailingTake, blank lines are taken, but not as the last line.
+func IndentTake(minIndent int) Taker {
+	indented := func(line string) bool { return spaces.Skim(line) >= minIndent }
+	take := TrailingTake(spaces.Intersects, indented)
+	return func(lines []string) int {
+		if len(lines) == 0 || !indented(lines[0]) {
+			return 0
+		}
+		return take(lines)
 	}
 }
 
@@ -305,17 +1297,127 @@ func TrailingTake(maybe, otherwise Pred[string]) Taker {
 	}
 }
 
+// UntilTake builds a Taker function that will take lines until its stop Taker
+// is able to take something.
+// Like with TrailingTake, lines described by maybe are not taken as the last
+// line.
+func UntilTake(maybe Pred[string], stop Taker) Taker {
+	return func(lines []string) int {
+		lastCore := -1
+		for i, line := range lines {
+			if stop(lines[i:]) > 0 {
+				break
+			}
+			if !maybe(line) {
+				lastCore = i
+			}
+		}
+		return lastCore + 1
+	}
+}
+
 ///////////////////////
 // Makers and bakers //
 ///////////////////////
-// ReSectionMake generates a section Maker with a regexp that produces two groups:
-//  - The section specifier whose length is the level.
-//  - The section title.
+// ReSectionMake generates a section Maker with a regexp that produces two named
+// groups:
+//  - level, the section specifier whose length is the level.
+//  - title, the section title.
 // The correctness of the regex is of course the responsibility of the caller.
 func ReSectionMake(r regex) Maker {
 	return func(lines []string) ElementImpl {
-		groups := r.Groups(lines[0])
-		return SectionElement{Level: len(groups[1]), Title: groups[2]}
+		groups := r.NamedGroups(lines[0])
+		return SectionElement{Level: len(groups["level"]), Title: groups["title"]}
+	}
+}
+
+// TrimRightBk removes the whitespace at the end of a line.
+func TrimRightBk(l string) string { return spaces.TrimRight(l) }
+
+// PipeBake combines the given Bakers into one, applying them from left to
+// right.
+func PipeBake(bakers ...Baker) Baker {
+	return func(l string) string {
+		for _, bk := range bakers {
+			l = bk(l)
+		}
+		return l
+	}
+}
+
+// DedentBake generates a Baker removing up to n spaces or tabs at the start of
+// a line.
+// Blank lines are emptied.
+func DedentBake(n int) Baker {
+	return func(line string) string {
+		indent := spaces.Skim(line)
+		if indent == -1 {
+			return ""
+		}
+		if indent > n {
+			indent = n
+		}
+		return line[indent:]
+	}
+}
+
+// ExpandTabs returns a copy of the lines where tabs are replaced by the spaces
+// reaching the next tab stop, tab stops being width columns apart.
+// Columns are counted in runes.
+// The lines are copied unchanged when width is not positive.
+func ExpandTabs(lines []string, width int) []string {
+	if width <= 0 {
+		return append([]string{}, lines...)
+	}
+	return Map(func(line string) string {
+		if !strings.ContainsRune(line, '\t') {
+			return line
+		}
+		res := strings.Builder{}
+		column := 0
+		for _, r := range line {
+			if r == '\t' {
+				n := width - column%width
+				res.WriteString(strings.Repeat(" ", n))
+				column += n
+				continue
+			}
+			res.WriteRune(r)
+			column++
+		}
+		return res.String()
+	}, lines)
+}
+
+// ContractTabs returns a copy of the lines where the indentation is made of
+// tabs, each replacing width spaces, the remaining spaces being kept.
+// It undoes ExpandTabs on indentation, which is typically needed after fusing.
+// The lines are copied unchanged when width is not positive.
+func ContractTabs(lines []string, width int) []string {
+	if width <= 0 {
+		return append([]string{}, lines...)
+	}
+	return Map(func(line string) string {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		return strings.Repeat("\t", indent/width) + line[indent/width*width:]
+	}, lines)
+}
+
+// MonoMake generates a Maker for one line elements, giving the first line to
+// mk.
+func MonoMake(mk func(string) ElementImpl) Maker {
+	return func(lines []string) ElementImpl { return mk(lines[0]) }
+}
+
+// GroupMaker represents a function making an element from the groups captured
+// on the first line of a block and from the lines inside the block.
+type GroupMaker func(groups []string, inner []string) ElementImpl
+
+// ReBetweenMake generates a Maker for blocks taken by RegexpBetweenTake, giving
+// the groups captured by begin to mk.
+func ReBetweenMake(begin regex, mk GroupMaker) Maker {
+	return func(lines []string) ElementImpl {
+		return mk(begin.Groups(lines[0]), lines[1:len(lines)-1])
 	}
 }
 
@@ -336,6 +1438,7 @@ func SpaceMk(ls []string) ElementImpl { return SpaceElement{Raw: ls} }
 
 // SpaceRule parses lines composed exclusively of whitespace.
 var SpaceRule = Rule{
+	Name: "space",
 	Take: GreedyTake(spaces.Intersects),
 	Bake: NoBk,
 	Make: SpaceMk,
@@ -350,6 +1453,71 @@ var SpaceRule = Rule{
 // Fusing is therefore the dual of parsing.
 type Fuser func(Elements) ([]string, error)
 
+// Emitter represents a function receiving lines as they are fused.
+type Emitter func(lines ...string)
+
+// StreamFuser represents a Fuser that emits the lines instead of returning
+// them, so that they do not need to be held in memory.
+type StreamFuser func(Elements, Emitter) error
+
+// CollectFuse fuses elements with a StreamFuser, returning the lines.
+func CollectFuse(fuse StreamFuser, matter Elements) ([]string, error) {
+	res := slice[string]{}
+	emit := func(lines ...string) { res.Add(lines...) }
+	if err := fuse(matter, emit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// FuseTable associates element types with the functions fusing them, to build
+// fusers without a type switch.
+// Functions are registered with FuseOn.
+type FuseTable map[reflect.Type]func(Element) []string
+
+// FuseOn registers in table the function fusing the elements of type T.
+func FuseOn[T ElementImpl](table FuseTable, fun func(T) []string) {
+	table[reflect.TypeOf((*T)(nil)).Elem()] = func(part Element) []string {
+		return fun(part.ElementImpl.(T))
+	}
+}
+
+// StreamFuserFromTable builds a StreamFuser from a table.
+// Elements whose type is not in the table cannot be fused by lang.
+func StreamFuserFromTable(lang string, table FuseTable) StreamFuser {
+	return func(matter Elements, emit Emitter) error {
+		for _, part := range matter {
+			fun, ok := table[reflect.TypeOf(part.ElementImpl)]
+			if !ok {
+				return fuseError(lang, part)
+			}
+			emit(fun(part)...)
+		}
+		return nil
+	}
+}
+
+// FuserFromTable is like StreamFuserFromTable, but builds a Fuser.
+func FuserFromTable(lang string, table FuseTable) Fuser {
+	stream := StreamFuserFromTable(lang, table)
+	return func(matter Elements) ([]string, error) {
+		return CollectFuse(stream, matter)
+	}
+}
+
+// Header represents a function parsing what can only appear at the very start
+// of a document, returning the elements it made and the remaining lines.
+type Header func([]string) (Elements, []string, error)
+
+// Refiner represents a pass over freshly parsed elements.
+// It is meant to resolve what can only be known at the document level, since
+// rules only see the lines they are given.
+type Refiner func(Elements) (Elements, error)
+
+// Commenter represents a function turning lines into a comment, to include
+// content that is not otherwise supported.
+type Commenter func([]string) ElementImpl
+
 // Language represents a language, be it prose-based or code-based, and all that
 // is needed to manipulate it.
 type Language struct {
@@ -357,8 +1525,111 @@ type Language struct {
 	Extensions  []string
 	Parser      Rules
 	Fuse        Fuser
+	Stream      StreamFuser  // Optional, Fuse is used by FuseWriter when absent.
+	Header      Header       // Optional.
+	Refine      Refiner      // Optional.
+	Comment     Commenter    // Optional.
+	TabWidth    int          // Optional, tabs are expanded to this width before parsing when positive.
+	Markers     Pred[string] // Optional, matches the lines only this language uses, see DetectLanguage.
 }
 
+// FuseWriter fuses elements and writes the lines to w, separated by newlines.
+func (l Language) FuseWriter(matter Elements, w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	first := true
+	emit := func(lines ...string) { // Write errors are kept by buf.
+		for _, line := range lines {
+			if !first {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString(line)
+			first = false
+		}
+	}
+
+	if l.Stream != nil {
+		if err := l.Stream(matter, emit); err != nil {
+			return err
+		}
+	} else {
+		lines, err := l.Fuse(matter)
+		if err != nil {
+			return err
+		}
+		emit(lines...)
+	}
+	return buf.Flush()
+}
+
+// FuseOriginal is like Fuse, but the elements that were not modified since they
+// were parsed are reproduced from their original lines, so that rewriting a
+// document only changes what was modified.
+// An element is considered unmodified when its original lines are parsed into
+// the same element.
+func (l Language) FuseOriginal(matter Elements) ([]string, error) {
+	res := []string{}
+	modified := Elements{}
+	flush := func() error { // Modified elements are fused together, to keep their context.
+		if len(modified) == 0 {
+			return nil
+		}
+		lines, err := l.Fuse(modified)
+		res = append(res, lines...)
+		modified = Elements{}
+		return err
+	}
+
+	for _, part := range matter {
+		if !l.unmodified(part) {
+			modified = append(modified, part)
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		res = append(res, part.Original...)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// unmodified returns true if parsing the original lines of an element makes
+// the same element.
+func (l Language) unmodified(part Element) bool {
+	if part.Original == nil {
+		return false
+	}
+	parsed, err := l.Parser.Parse(part.Original)
+	if err == nil && l.Refine != nil {
+		parsed, err = l.Refine(parsed)
+	}
+	return err == nil && len(parsed) == 1 && reflect.DeepEqual(parsed[0].ElementImpl, part.ElementImpl)
+}
+
+// Parse parses lines with the header, the rules and the refiner of the language.
+// When TabWidth is positive, tabs are expanded beforehand, so the lines kept in
+// Element.Original are expanded as well.
 func (l Language) Parse(lines []string) (Elements, error) {
-	return l.Parser.Parse(lines)
+	if l.TabWidth > 0 {
+		lines = ExpandTabs(lines, l.TabWidth)
+	}
+	head := Elements{}
+	total := len(lines)
+	if l.Header != nil {
+		var err error
+		if head, lines, err = l.Header(lines); err != nil {
+			return nil, err
+		}
+	}
+	res, err := l.Parser.parseFrom(lines, total-len(lines)+1)
+	if err != nil {
+		return nil, err
+	}
+	res = append(head, res...)
+	if l.Refine == nil {
+		return res, nil
+	}
+	return l.Refine(res)
 }
@@ -0,0 +1,46 @@
+package parse
+
+import (
+	"fmt"
+	"testing"
+)
+
+// largeOrgDocument makes an Org document of n sections holding prose, code and
+// a list.
+func largeOrgDocument(n int) []string {
+	res := []string{}
+	for i := 0; i < n; i++ {
+		res = append(res,
+			fmt.Sprint("* Section ", i),
+			"Some prose introducing the code.",
+			"",
+			"#+begin_src go :tangle main.go",
+			"func main() {",
+			"\tfmt.Println(\"hello\")",
+			"}",
+			"#+end_src",
+			"",
+			"- first item",
+			"- second item",
+			"",
+		)
+	}
+	return res
+}
+
+// BenchmarkParseOrg compares the rules baking with NoBk, whose lines are not
+// copied, to the same rules baking with an identity function.
+func BenchmarkParseOrg(b *testing.B) {
+	document := largeOrgDocument(1000)
+	identity := func(line string) string { return line }
+	for name, rules := range map[string]Rules{"nobk": OrgRules, "identity": OrgRules.WithBake(identity)} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := rules.Parse(document); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
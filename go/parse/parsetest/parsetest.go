@@ -0,0 +1,255 @@
+// Package parsetest provides helpers to check that languages implemented with
+// the parse package behave as expected.
+package parsetest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+)
+
+// AssertRoundTrip parses input with lang then fuses it back, failing the test
+// when the result differs from input.
+// No normalization is applied, so input must already be written the way lang
+// fuses it (e.g. Org tags aligned at the expected column).
+func AssertRoundTrip(t testing.TB, lang parse.Language, input []string) {
+	t.Helper()
+	parsed, err := lang.Parse(input)
+	if err != nil {
+		t.Errorf("parse error: %s", err)
+		return
+	}
+	fused, err := lang.Fuse(parsed)
+	if err != nil {
+		t.Errorf("fuse error: %s", err)
+		return
+	}
+	expected, actual := strings.Join(input, "\n"), strings.Join(fused, "\n")
+	if expected != actual {
+		t.Errorf("round trip mismatch:\n%s\n----- expected above, got below -----\n%s", expected, actual)
+	}
+}
+
+// OrgCorpus holds Org snippets that are tricky to parse, meant to be given to
+// AssertRoundTrip.
+var OrgCorpus = map[string][]string{
+	"nested blocks": {
+		"#+begin_quote",
+		"#+begin_verse",
+		"Nested",
+		"#+end_verse",
+		"#+end_quote",
+	},
+	"empty code block": {
+		"#+begin_src sh",
+		"#+end_src",
+	},
+	"code block without language": {
+		"#+begin_src",
+		"echo",
+		"#+end_src",
+	},
+	"metadata with colons": {
+		"#+title: Parsing: a story",
+		"#+property: header-args :tangle no",
+	},
+	"indented code block": {
+		"* Section",
+		"  #+begin_src go",
+		"  x := 1",
+		"",
+		"  y := 2",
+		"  #+end_src",
+	},
+	"section with keyword and tags": {
+		"** TODO [#A] Things to do                                         :work:home:",
+	},
+	"properties drawer": {
+		"* Section",
+		":PROPERTIES:",
+		":CUSTOM_ID: section",
+		":END:",
+	},
+	"fixed width": {
+		": output",
+		":",
+		": more output",
+	},
+	"list with nested items": {
+		"- first",
+		"  - nested",
+		"- [X] checked",
+	},
+	"description list": {
+		"- term :: definition",
+		"- spaced  ::  definition",
+		"  continued",
+		"- empty ::",
+	},
+	"paragraphs without blank line": {"First paragraph.", "Second paragraph."},
+	"paragraphs separated by one blank line": {
+		"First paragraph.",
+		"",
+		"Second paragraph.",
+	},
+	"paragraphs separated by three blank lines": {
+		"First paragraph.",
+		"",
+		"",
+		"",
+		"Second paragraph.",
+	},
+	"blank lines holding whitespace": {"First paragraph.", "  ", "\t", "Second paragraph.", "", ""},
+}
+
+// MarkdownCorpus holds Markdown snippets that are tricky to parse, meant to be
+// given to AssertRoundTrip.
+var MarkdownCorpus = map[string][]string{
+	"paragraphs without blank line": {"First paragraph.", "Second paragraph."},
+	"paragraphs separated by one blank line": {
+		"First paragraph.",
+		"",
+		"Second paragraph.",
+	},
+	"paragraphs separated by three blank lines": {
+		"First paragraph.",
+		"",
+		"",
+		"",
+		"Second paragraph.",
+	},
+	"blank lines around code": {
+		"Text.",
+		"",
+		"",
+		"```go",
+		"x := 1",
+		"",
+		"```",
+		"",
+		"",
+		"",
+		"- item",
+	},
+}
+
+// CheckParse is meant to be the body of a fuzz target: it parses data with
+// lang, failing the test if parsing panics or if a successful parse does not
+// round-trip.
+// Since fusing normalizes some constructs, what is checked is that the fused
+// document round-trips.
+// Parse errors are expected on arbitrary data and are therefore not failures.
+// See FuzzOrgParse in the tests of the parse package.
+func CheckParse(t testing.TB, lang parse.Language, data []byte) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("panic on %q: %v", data, r)
+		}
+	}()
+	parsed, err := lang.Parse(strings.Split(string(data), "\n"))
+	if err != nil {
+		return
+	}
+	normalized, err := lang.Fuse(parsed)
+	if err != nil {
+		t.Fatalf("fuse error on %q: %s", data, err)
+	}
+	AssertRoundTrip(t, lang, normalized)
+}
+
+// Repr returns a snapshot of elements, made of the kind of each element
+// followed by the lines of its Repr, indented.
+func Repr(matter parse.Elements) []string {
+	res := []string{}
+	for _, part := range matter {
+		res = append(res, part.TypeName())
+		for _, line := range part.Repr() {
+			res = append(res, "  "+line)
+		}
+	}
+	return res
+}
+
+// AssertRepr parses input with lang, failing the test when the snapshot of the
+// elements, see Repr, differs from golden.
+func AssertRepr(t testing.TB, lang parse.Language, input, golden []string) {
+	t.Helper()
+	parsed, err := lang.Parse(input)
+	if err != nil {
+		t.Errorf("parse error: %s", err)
+		return
+	}
+	expected, actual := strings.Join(golden, "\n"), strings.Join(Repr(parsed), "\n")
+	if expected != actual {
+		t.Errorf("repr mismatch:\n%s\n----- expected above, got below -----\n%s", expected, actual)
+	}
+}
+
+// ReprGolden is an Org snippet along with its snapshot.
+type ReprGolden struct {
+	Input []string
+	Repr  []string
+}
+
+// OrgReprCorpus pins the Repr of each kind of element, meant to be given to
+// AssertRepr.
+var OrgReprCorpus = map[string]ReprGolden{
+	"prose": {
+		Input: []string{"Some prose.", "", "More."},
+		Repr:  []string{"prose", "  Some prose.", "  ", "  More."},
+	},
+	"section": {
+		Input: []string{"* TODO [#A] Title :tag:"},
+		Repr:  []string{"section", "  level=1, title=Title, todo=TODO, priority=A, tags=tag"},
+	},
+	"metadata": {
+		Input: []string{"#+title: Document", "#+name: block"},
+		Repr:  []string{"metadata", "  title=Document", "metadata", "  name=block, scope=element"},
+	},
+	"drawer": {
+		Input: []string{":PROPERTIES:", ":ID: x", ":END:"},
+		Repr:  []string{"drawer", "  name=PROPERTIES, props=:ID x"},
+	},
+	"code with results": {
+		Input: []string{"#+begin_src go :tangle main.go", "x := 1", "#+end_src", "", "#+RESULTS:", ": 1"},
+		Repr:  []string{"code", "  lang=go", "  Params=:tangle main.go", "  x := 1", "  Results:", "  : 1"},
+	},
+	"indented code": {
+		Input: []string{"  #+begin_src sh", "  echo", "  #+end_src"},
+		Repr:  []string{"code", "  lang=sh", "  Params=", `  indent="  "`, "  echo"},
+	},
+	"examples": {
+		Input: []string{"#+begin_example", "out", "#+end_example", ": fixed"},
+		Repr:  []string{"example", "  style=block", "  out", "example", "  style=fixed", "  fixed"},
+	},
+	"quote": {
+		Input: []string{"#+begin_quote", "q", "#+end_quote"},
+		Repr:  []string{"quote", "  level=1", "  q"},
+	},
+	"block": {
+		Input: []string{"#+begin_note", "n", "#+end_note"},
+		Repr:  []string{"block", "  type=note", "  n"},
+	},
+	"uppercase blocks": {
+		Input: []string{"#+BEGIN_EXAMPLE", "out", "#+END_EXAMPLE", "#+BEGIN_VERSE", "v", "#+END_VERSE"},
+		Repr:  []string{"example", "  style=block", "  out", "block", "  type=verse", "  v"},
+	},
+	"export": {
+		Input: []string{"#+begin_export html", "<br>", "#+end_export"},
+		Repr:  []string{"export", "  backend=html", "  <br>"},
+	},
+	"comment": {
+		Input: []string{"# comment"},
+		Repr:  []string{"comment", "  style=line", "  # comment"},
+	},
+	"list": {
+		Input: []string{"  - [X] done", "    - nested"},
+		Repr:  []string{"list", "  ordered=false, indent=2", "  - checked=true,partial=false done", "    - nested"},
+	},
+	"footnote": {
+		Input: []string{"[fn:1] Note."},
+		Repr:  []string{"footnote", "  label=1", "  Note."},
+	},
+}
@@ -0,0 +1,131 @@
+package parse
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Registry holds languages, to find them by identifier or by file extension.
+// When several languages claim the same identifier or extension, the first
+// registered one is chosen.
+type Registry struct {
+	langs   []Language
+	Default string // Identifier of the language chosen when detection is inconclusive.
+}
+
+// NewRegistry creates a registry holding the given languages.
+func NewRegistry(langs ...Language) *Registry {
+	res := &Registry{}
+	for _, lang := range langs {
+		res.Register(lang)
+	}
+	return res
+}
+
+// Register adds a language to the registry.
+func (r *Registry) Register(lang Language) {
+	r.langs = append(r.langs, lang)
+}
+
+// WithDefault sets the default language of the registry, returning the
+// registry.
+func (r *Registry) WithDefault(id string) *Registry {
+	r.Default = id
+	return r
+}
+
+// ByExtension returns the language of a file, based on its extension.
+// Extensions are compared case-insensitively.
+func (r *Registry) ByExtension(path string) (Language, bool) {
+	ext := filepath.Ext(path)
+	for _, lang := range r.langs {
+		for _, candidate := range lang.Extensions {
+			if strings.EqualFold(candidate, ext) {
+				return lang, true
+			}
+		}
+	}
+	return Language{}, false
+}
+
+// ByIdentifier returns the language having the given identifier.
+func (r *Registry) ByIdentifier(id string) (Language, bool) {
+	for _, lang := range r.langs {
+		for _, candidate := range lang.Identifiers {
+			if candidate == id {
+				return lang, true
+			}
+		}
+	}
+	return Language{}, false
+}
+
+// Languages is the registry of the languages defined in this package.
+var Languages = NewRegistry(OrgLang, MarkdownLang, RSTLang, LaTeXLang, LHSLang, MediaWikiLang).WithDefault("org")
+
+///////////////
+// Detection //
+///////////////
+
+// detectLines is the number of lines examined by DetectLanguage.
+const detectLines = 100
+
+// DetectLanguage guesses the language of a document from its first lines,
+// returning the registered language whose markers match the most lines, along
+// with a confidence between 0 and 1.
+// The confidence is the margin between the best score and the next one, a
+// score being the proportion of non-blank lines matched by the markers of a
+// language, which are the lines only this language uses, like the Markdown
+// headings or the Org keywords.
+// Languages without markers are never detected, and ties fall back to the
+// default language of the registry, when it is among the best ones.
+func DetectLanguage(lines []string, registry *Registry) (Language, float64) {
+	if len(lines) > detectLines {
+		lines = lines[:detectLines]
+	}
+	best, second := -1.0, 0.0
+	var res Language
+	for _, lang := range registry.langs {
+		score := detectScore(lang.Markers, lines)
+		switch {
+		case score > best:
+			best, second, res = score, best, lang
+		case score == best:
+			second = score
+			if slice[string](lang.Identifiers).Contains(func(id string) bool { return id == registry.Default }) {
+				res = lang
+			}
+		case score > second:
+			second = score
+		}
+	}
+	if best < 0 {
+		return res, 0
+	}
+	if second < 0 {
+		second = 0
+	}
+	return res, best - second
+}
+
+// detectScore returns the proportion of non-blank lines matched by markers,
+// 0 when there are no markers.
+func detectScore(markers Pred[string], lines []string) float64 {
+	if markers == nil {
+		return 0
+	}
+	marked, total := 0, 0
+	for _, line := range lines {
+		if spaces.Trim(line) == "" {
+			continue
+		}
+		total++
+		if markers(line) {
+			marked++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(marked) / float64(total)
+}
@@ -0,0 +1,30 @@
+package parse_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	for input, expected := range map[string]string{
+		"# Title\n\nSome text.":                       "markdown",
+		"* Title\n\nSome text.":                       "org",
+		"#+title: Notes\n\n- item":                    "org",
+		"Text.\n\n```go\nx := 1\n```":                 "markdown",
+		"Title\n=====\n\n.. code-block:: sh\n\n   ls": "rst",
+		"== Section ==\nText.":                        "mediawiki",
+		"\\section{Title}\nText.":                     "latex",
+	} {
+		lang, confidence := parse.DetectLanguage(strings.Split(input, "\n"), parse.Languages)
+		if lang.Identifiers[0] != expected || confidence <= 0 {
+			t.Errorf("%q detected as %s with confidence %.2f, expected %s", input, lang.Identifiers[0], confidence, expected)
+		}
+	}
+
+	lang, confidence := parse.DetectLanguage([]string{"Just prose."}, parse.Languages)
+	if lang.Identifiers[0] != "org" || confidence != 0 {
+		t.Errorf("prose detected as %s with confidence %.2f, expected the default with no confidence", lang.Identifiers[0], confidence)
+	}
+}
@@ -0,0 +1,16 @@
+package parse_test
+
+import (
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+	"github.com/mooss/litlib/parse/parsetest"
+)
+
+func TestOrgRepr(t *testing.T) {
+	for name, golden := range parsetest.OrgReprCorpus {
+		t.Run(name, func(t *testing.T) {
+			parsetest.AssertRepr(t, parse.OrgLang, golden.Input, golden.Repr)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package parse_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+	"github.com/mooss/litlib/parse/parsetest"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, corpus := range []struct {
+		lang     parse.Language
+		snippets map[string][]string
+	}{
+		{parse.OrgLang, parsetest.OrgCorpus},
+		{parse.MarkdownLang, parsetest.MarkdownCorpus},
+	} {
+		for name, input := range corpus.snippets {
+			t.Run(corpus.lang.Identifiers[0]+"/"+name, func(t *testing.T) {
+				parsetest.AssertRoundTrip(t, corpus.lang, input)
+			})
+		}
+	}
+}
+
+func TestBlankLinesRoundTrip(t *testing.T) {
+	for _, lang := range []parse.Language{parse.OrgLang, parse.MarkdownLang} {
+		for _, blanks := range []string{"", "\n", "\n\n\n"} {
+			data := "First paragraph.\n" + blanks + "Second paragraph.\n"
+			parsetest.CheckParse(t, lang, []byte(data))
+			parsetest.AssertRoundTrip(t, lang, strings.Split(data, "\n"))
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+///////////////////
+// Text matching //
+///////////////////
+
+var rstCodeRe = re(`^\.\. (code-block|code|sourcecode)::[ \t]*(\S*)[ \t]*$`)
+var rstOptionRe = re(`^:([^:]+):[ \t]*(.*)$`)
+var rstDirectiveRe = re(`^\.\. [\w-]+::`)
+var rstPunctuation = str("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~")
+
+// rstAdornments are the adornments used by default for each level, when the
+// adornment of a section is unknown.
+var rstAdornments = "=-~^\"'+#*"
+
+// rstIndent is the indentation used to fuse directive bodies.
+const rstIndent = "   "
+
+// rstDefaultDirective is the directive used to fuse code blocks whose directive
+// is unknown.
+const rstDefaultDirective = "code-block"
+
+// rstAdornment returns true if the line is only made of one repeated
+// punctuation character.
+func rstAdornment(line string) bool {
+	if line == "" || !rstPunctuation.HasRune(rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// rstTitle returns true if the line can be used as a section title.
+func rstTitle(line string) bool {
+	return !spaces.Intersects(line) && !spaces.HasRune(rune(line[0]))
+}
+
+// rstIndented returns true if the line starts with whitespace.
+func rstIndented(line string) bool {
+	return line != "" && spaces.HasRune(rune(line[0]))
+}
+
+////////////
+// Takers //
+////////////
+
+// rstSectionTake takes a section title and its adornments, looking ahead to
+// find the underline.
+func rstSectionTake(lines []string) int {
+	switch {
+	case len(lines) >= 3 && rstAdornment(lines[0]) && lines[2] == lines[0] &&
+		!spaces.Intersects(lines[1]) && len(lines[0]) >= len(spaces.TrimRight(lines[1])):
+		return 3 // Overline, title and underline.
+	case len(lines) >= 2 && rstTitle(lines[0]) && rstAdornment(lines[1]) &&
+		len(lines[1]) >= len(spaces.TrimRight(lines[0])):
+		return 2 // Title and underline.
+	}
+	return 0
+}
+
+// rstCodeTake takes a code directive and its indented body.
+func rstCodeTake(lines []string) int {
+	if len(lines) == 0 || !rstCodeRe.Match(lines[0]) {
+		return 0
+	}
+	last := 0
+	for i, line := range lines[1:] {
+		if spaces.Intersects(line) {
+			continue
+		}
+		if !rstIndented(line) {
+			break
+		}
+		last = i + 1
+	}
+	return last + 1
+}
+
+func rstStructureTake(lines []string) int {
+	if take := rstSectionTake(lines); take > 0 {
+		return take
+	}
+	return rstCodeTake(lines)
+}
+
+////////////
+// Makers //
+////////////
+
+// RSTSectionMk makes a section element from RST lines.
+// The level is left unknown, it is resolved by RSTRefiner.
+func RSTSectionMk(lines []string) ElementImpl {
+	res := SectionElement{}
+	if len(lines) == 3 {
+		res.Params.Add("overline", Values{lines[0]})
+		lines = lines[1:]
+	}
+	res.Title = spaces.Trim(lines[0])
+	res.Params.Add("underline", Values{lines[1]})
+	return res
+}
+
+// RSTCodeMk makes a code element from a RST code directive.
+func RSTCodeMk(lines []string) ElementImpl {
+	groups := rstCodeRe.Groups(lines[0])
+	res := CodeElement{Lang: groups[2], Params: Parameters{}, Style: groups[1]}
+	body := lines[1:]
+	for len(body) > 0 && rstIndented(body[0]) {
+		groups := rstOptionRe.Groups(spaces.Trim(body[0]))
+		if groups == nil {
+			break
+		}
+		res.Params.Add(groups[1], spaces.Fields(groups[2]))
+		body = body[1:]
+	}
+	for len(body) > 0 && spaces.Intersects(body[0]) {
+		body = body[1:]
+	}
+	res.Raw = dedent(body)
+	return res
+}
+
+// dedent removes the common leading whitespace of the lines.
+// Whitespace-only lines are emptied.
+func dedent(lines []string) []string {
+	common := -1
+	for _, line := range lines {
+		indent := spaces.Skim(line)
+		if indent != -1 && (common == -1 || indent < common) {
+			common = indent
+		}
+	}
+	res := make([]string, len(lines))
+	for i, line := range lines {
+		if !spaces.Intersects(line) {
+			res[i] = line[common:]
+		}
+	}
+	return res
+}
+
+///////////////////////////////////
+// High-level parsing and fusing //
+///////////////////////////////////
+
+// RSTRules is a sequence of rules able to parse a reStructuredText file.
+var RSTRules = Rules{
+	Rule{ // Section, hierarchical delimiter of the document.
+		Name: "section",
+		Take: rstSectionTake,
+		Bake: NoBk,
+		Make: RSTSectionMk,
+	},
+	Rule{ // Code, content meant for machine consumption.
+		Name: "code",
+		Take: rstCodeTake,
+		Bake: NoBk,
+		Make: RSTCodeMk,
+	},
+	SpaceRule, // Whitespace, content that can typically be ignored.
+	Rule{ // Prose, content meant for human consumption.
+		Name: "prose",
+		Take: UntilTake(spaces.Intersects, rstStructureTake),
+		Bake: NoBk,
+		Make: ProseMk,
+	},
+}
+
+// rstStyle identifies the adornment style of a section.
+func rstStyle(s SectionElement) string {
+	style := ""
+	if over := s.Params.Get("overline"); over != nil && len(*over) > 0 {
+		style += (*over)[0][:1]
+	}
+	if under := s.Params.Get("underline"); under != nil && len(*under) > 0 {
+		style += "/" + (*under)[0][:1]
+	}
+	return style
+}
+
+// RSTRefiner assigns the level of sections, by the order in which each
+// adornment style first appears in the document.
+// The level is also stored in the level parameter, so that the fuser knows
+// whether the adornments still match it.
+func RSTRefiner(matter Elements) (Elements, error) {
+	styles := []string{}
+	for i, part := range matter {
+		section, ok := part.ElementImpl.(SectionElement)
+		if !ok {
+			continue
+		}
+		style := rstStyle(section)
+		section.Level = 0
+		for j, known := range styles {
+			if known == style {
+				section.Level = j + 1
+			}
+		}
+		if section.Level == 0 {
+			styles = append(styles, style)
+			section.Level = len(styles)
+		}
+		section.Params.Set("level", Values{strconv.Itoa(section.Level)})
+		matter[i].ElementImpl = section
+	}
+	return matter, nil
+}
+
+// rstParsedLevel returns true if the section still has the level it was
+// parsed with.
+func rstParsedLevel(s SectionElement) bool {
+	vp := s.Params.Get("level")
+	if vp == nil {
+		return false
+	}
+	level, err := vp.Int()
+	return err == nil && level == s.Level
+}
+
+// rstStyles associates the levels of the sections that kept their parsed level
+// with their adornment style.
+func rstStyles(matter Elements) map[int]string {
+	res := map[int]string{}
+	for _, part := range matter {
+		section, ok := part.AsSection()
+		if _, known := res[section.Level]; ok && !known && rstParsedLevel(section) {
+			res[section.Level] = rstStyle(section)
+		}
+	}
+	return res
+}
+
+// rstAdorn returns the adornments of a section, at least as long as its title.
+// The parsed adornments are kept as long as the section has its parsed level,
+// otherwise they follow the style of the level in styles or, failing that, a
+// default character of no other style.
+func rstAdorn(s SectionElement, styles map[int]string) (over, under string) {
+	if rstParsedLevel(s) {
+		if vp := s.Params.Get("overline"); vp != nil && len(*vp) > 0 {
+			over = (*vp)[0]
+		}
+		if vp := s.Params.Get("underline"); vp != nil && len(*vp) > 0 {
+			under = (*vp)[0]
+		}
+	} else if style, known := styles[s.Level]; known {
+		over, under, _ = strings.Cut(style, "/")
+	}
+	if under == "" {
+		used := map[string]bool{}
+		for _, style := range styles {
+			used[style] = true
+		}
+		start := 0
+		if s.Level > 0 {
+			start = (s.Level - 1) % len(rstAdornments)
+		}
+		under = rstAdornments[start : start+1]
+		for i := range rstAdornments {
+			char := rstAdornments[(start+i)%len(rstAdornments)]
+			if !used["/"+string(char)] {
+				under = string(char)
+				break
+			}
+		}
+	}
+
+	width := utf8.RuneCountInString(s.Title)
+	if len(over) > width {
+		width = len(over)
+	}
+	if len(under) > width {
+		width = len(under)
+	}
+	if over != "" {
+		over = strings.Repeat(over[:1], width)
+	}
+	return over, strings.Repeat(under[:1], width)
+}
+
+// RSTStreamFuser can reconstruct the lines of a reStructuredText document from parsed
+// elements.
+func RSTStreamFuser(matter Elements, emit Emitter) error {
+	styles := rstStyles(matter)
+	for _, part := range matter {
+		switch p := part.ElementImpl.(type) {
+		case CodeElement:
+			directive := p.Style
+			if directive == "" {
+				directive = rstDefaultDirective
+			}
+			emit(spaces.TrimRight(".. " + directive + ":: " + p.Lang))
+			for _, param := range p.Params {
+				emit(spaces.TrimRight(rstIndent + ":" + param.Key + ": " + strings.Join(param.Values, " ")))
+			}
+			if len(p.Raw) > 0 { // A blank line separates the body from the options.
+				emit("")
+			}
+			for _, line := range p.Raw {
+				if line == "" {
+					emit(line)
+				} else {
+					emit(rstIndent + line)
+				}
+			}
+
+		case ProseElement:
+			emit(p.Raw...)
+
+		case SectionElement:
+			over, under := rstAdorn(p, styles)
+			if over != "" {
+				emit(over)
+			}
+			emit(p.Title, under)
+
+		case SpaceElement:
+			emit(p.Raw...)
+
+		default:
+			return fuseError("rst", part)
+		}
+	}
+	return nil
+}
+
+// RSTFuser is like RSTStreamFuser, but returns the lines.
+func RSTFuser(matter Elements) ([]string, error) {
+	return CollectFuse(RSTStreamFuser, matter)
+}
+
+// RSTLang holds information needed to manipulate reStructuredText files.
+var RSTLang = Language{
+	Identifiers: []string{"rst", "restructuredtext"},
+	Extensions:  []string{".rst"},
+	Parser:      RSTRules,
+	Fuse:        RSTFuser,
+	Stream:      RSTStreamFuser,
+	Refine:      RSTRefiner,
+	Markers:     rstDirectiveRe.Match,
+}
@@ -0,0 +1,81 @@
+package parse_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+	"github.com/mooss/litlib/parse/parsetest"
+)
+
+func TestRSTCodeRoundTrip(t *testing.T) {
+	for name, input := range map[string][]string{
+		"code block":         {".. code-block:: python", "", "   print(1)"},
+		"code block options": {".. code-block:: python", "   :linenos:", "", "   print(1)"},
+		"empty code block":   {".. code-block:: python", "", "Prose."},
+		"empty with options": {".. code-block:: python", "   :linenos:"},
+		"code directive":     {".. code:: python", "", "   print(1)"},
+		"sourcecode":         {".. sourcecode:: python", "", "   print(1)"},
+		"no language":        {".. code::", "", "   print(1)"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsetest.AssertRoundTrip(t, parse.RSTLang, input)
+		})
+	}
+}
+
+func FuzzRSTParse(f *testing.F) {
+	f.Add([]byte("Title\n=====\n\n.. code-block:: python\n\n   print(1)\n"))
+	f.Add([]byte(".. code-block::"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parsetest.CheckParse(t, parse.RSTLang, data)
+	})
+}
+
+// fuseRST fuses elements into RST, failing the test on error.
+func fuseRST(t *testing.T, matter parse.Elements) []string {
+	t.Helper()
+	lines, err := parse.RSTLang.Fuse(matter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestRSTSectionAdornments(t *testing.T) {
+	input := []string{"=====", "Title", "=====", "", "Part", "----", "", "Other", "====="}
+	parsetest.AssertRoundTrip(t, parse.RSTLang, append([]string{}, input[4:]...))
+	parsetest.AssertRoundTrip(t, parse.RSTLang, input)
+
+	matter, err := parse.RSTLang.Parse(input[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := append(parse.Elements{}, matter...)
+	section, _ := renamed[0].AsSection()
+	section.Title = "Longer part"
+	renamed[0].ElementImpl = section
+	expected := []string{"Longer part", "-----------", "", "Other", "====="}
+	if lines := fuseRST(t, renamed); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("renamed section fused into %q, expected %q", lines, expected)
+	}
+
+	// Other is moved to the level of Part, whose style it takes.
+	moved := append(parse.Elements{}, matter...)
+	section, _ = moved[2].AsSection()
+	section.Level = 1
+	moved[2].ElementImpl = section
+	expected = []string{"Part", "----", "", "Other", "-----"}
+	if lines := fuseRST(t, moved); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("moved section fused into %q, expected %q", lines, expected)
+	}
+
+	shifted, err := parse.ShiftLevels(matter, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"Part", "----", "", "Other", "~~~~~"}
+	if lines := fuseRST(t, shifted); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("shifted sections fused into %q, expected %q", lines, expected)
+	}
+}
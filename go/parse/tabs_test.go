@@ -0,0 +1,31 @@
+package parse_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+)
+
+func TestTabsRoundTrip(t *testing.T) {
+	lines := []string{"\tindented", "\t\tnested", "  \tmixed", "no\ttab"}
+	expanded := parse.ExpandTabs(lines, 4)
+	if expected := []string{"    indented", "        nested", "    mixed", "no  tab"}; !reflect.DeepEqual(expanded, expected) {
+		t.Errorf("tabs expanded into %q, expected %q", expanded, expected)
+	}
+	if contracted := parse.ContractTabs(expanded[:2], 4); !reflect.DeepEqual(contracted, lines[:2]) {
+		t.Errorf("tabs contracted into %q", contracted)
+	}
+}
+
+func TestTabsNonPositiveWidth(t *testing.T) {
+	lines := []string{"\tindented", "    spaced"}
+	for _, width := range []int{0, -2} {
+		if expanded := parse.ExpandTabs(lines, width); !reflect.DeepEqual(expanded, lines) {
+			t.Errorf("tabs expanded with width %d into %q", width, expanded)
+		}
+		if contracted := parse.ContractTabs(lines, width); !reflect.DeepEqual(contracted, lines) {
+			t.Errorf("tabs contracted with width %d into %q", width, contracted)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package parse
+
+import "testing"
+
+func TestTakersOnEmptyInput(t *testing.T) {
+	always := func(string) bool { return true }
+	for name, take := range map[string]Taker{
+		"GreedyTake":        GreedyTake(always),
+		"FirstTake":         FirstTake(always),
+		"MonoTake":          MonoTake(always),
+		"CountTake":         CountTake(2),
+		"BetweenTake":       BetweenTake(always, always),
+		"RegexpBetweenTake": RegexpBetweenTake(orgBeginSrcRe, orgEndSrcRe),
+		"IndentTake":        IndentTake(2),
+		"TrailingTake":      TrailingTake(always, always),
+		"UntilTake":         UntilTake(always, FirstTake(always)),
+	} {
+		if taken := take([]string{}); taken != 0 {
+			t.Errorf("%s took %d lines of an empty input", name, taken)
+		}
+	}
+
+	rule := Rule{Take: FirstTake(always), Make: ReSectionMake(orgSectionRe)}
+	if rest, emitted, err := rule.Emit([]string{}); len(rest) != 0 || !emitted.void() || err != nil {
+		t.Errorf("a rule emitted %v, %v, %v from an empty input", rest, emitted, err)
+	}
+	if matter, err := OrgRules.Parse([]string{}); err != nil || len(matter) != 0 {
+		t.Errorf("parsing an empty input gives %v, %v", matter, err)
+	}
+}
+
+func TestParseLastRuleTakesEverything(t *testing.T) {
+	rules := Rules{
+		{Take: FirstTake(orgSectionRe.Match), Make: ReSectionMake(orgSectionRe)},
+		{Take: GreedyTake(func(string) bool { return true }), Bake: NoBk, Make: ProseMk},
+	}
+	matter, err := rules.Parse([]string{"* Title", "text", "more text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matter) != 2 || !matter[0].IsSection() || !matter[1].IsProse() {
+		t.Fatalf("parsed into %v", matter)
+	}
+	if span := matter[1].Span; span.StartLine != 2 || span.EndLine != 3 {
+		t.Errorf("prose spans %v", span)
+	}
+}
@@ -0,0 +1,40 @@
+package parse
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Tangle collects the code of the blocks having a tangle parameter, grouped by
+// target file.
+// The targets are relative to outdir and blocks sharing a target are
+// concatenated in document order.
+// Following Org, blocks whose target is no are skipped.
+// Nothing is written, this is left to the caller.
+func Tangle(matter Elements, outdir string) (map[string][]string, error) {
+	res := map[string][]string{}
+	for i, part := range matter {
+		code, ok := part.ElementImpl.(CodeElement)
+		if !ok {
+			continue
+		}
+		target := code.Params.Get("tangle")
+		if target == nil {
+			continue
+		}
+
+		name := strings.Join(*target, " ")
+		switch name {
+		case "no":
+			continue
+		case "":
+			return nil, fmt.Errorf("code block %d has no tangle target", i)
+		case "yes":
+			return nil, fmt.Errorf("code block %d cannot be tangled to the document name, which is unknown", i)
+		}
+		name = filepath.Join(outdir, name)
+		res[name] = append(res[name], code.Raw...)
+	}
+	return res, nil
+}
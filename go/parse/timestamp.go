@@ -0,0 +1,88 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timestampRe matches Org timestamps, capturing the opening bracket, the date,
+// the start and end times, the repeater, the warning delay and the closing
+// bracket.
+var timestampRe = re(`([<\[])(\d{4}-\d{2}-\d{2})(?: +[^\s\d>\]]+)?(?: +(\d{1,2}:\d{2})(?:-(\d{1,2}:\d{2}))?)?(?: +([.+]?\+\d+[hdwmy]))?(?: +(--?\d+[hdwmy]))?([>\]])`)
+
+// Timestamp is an Org timestamp, like `<2024-01-02 Tue 10:00 +1w>`.
+type Timestamp struct {
+	Raw      string    // Timestamp as written, which is never reformatted.
+	Active   bool      // Between angle brackets rather than square brackets.
+	Start    time.Time // Date and time, in the local time zone.
+	End      time.Time // End of a time range within the day, zero when there is none.
+	HasTime  bool      // Whether Start has a time, midnight otherwise.
+	Repeater string    // Like +1w, empty when there is none.
+	Warning  string    // Like -2d, empty when there is none.
+	Element  int       // Index of the element containing the timestamp.
+	Offset   int       // Byte offset of the timestamp in the text of the element.
+}
+
+// ParseTimestamp parses a timestamp, which must be the whole of s.
+func ParseTimestamp(s string) (Timestamp, error) {
+	loc := timestampRe.FindStringSubmatchIndex(s)
+	if loc == nil || loc[0] != 0 || loc[1] != len(s) {
+		return Timestamp{}, fmt.Errorf("invalid timestamp `%s`", s)
+	}
+	return timestampFrom(s, loc)
+}
+
+// timestampFrom makes a timestamp from the indices matched by timestampRe in s.
+func timestampFrom(s string, loc []int) (Timestamp, error) {
+	group := func(i int) string {
+		if loc[2*i] < 0 {
+			return ""
+		}
+		return s[loc[2*i]:loc[2*i+1]]
+	}
+	res := Timestamp{
+		Raw:      s[loc[0]:loc[1]],
+		Active:   group(1) == "<",
+		Repeater: group(5),
+		Warning:  group(6),
+	}
+	if (group(1) == "<") != (group(7) == ">") {
+		return Timestamp{}, fmt.Errorf("mismatched brackets in timestamp `%s`", res.Raw)
+	}
+
+	var err error
+	date, start, end := group(2), group(3), group(4)
+	if start == "" {
+		res.Start, err = time.ParseInLocation("2006-01-02", date, time.Local)
+	} else {
+		res.HasTime = true
+		res.Start, err = time.ParseInLocation("2006-01-02 15:04", date+" "+start, time.Local)
+	}
+	if err == nil && end != "" {
+		res.End, err = time.ParseInLocation("2006-01-02 15:04", date+" "+end, time.Local)
+	}
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp `%s`: %w", res.Raw, err)
+	}
+	return res, nil
+}
+
+// ParseTimestamps reports the valid Org timestamps found in prose and section
+// titles, in document order.
+// Code is never scanned and the elements are not modified.
+func ParseTimestamps(matter Elements) []Timestamp {
+	res := []Timestamp{}
+	for i, part := range matter {
+		text := strings.Join(proseLines(part), "\n")
+		for _, loc := range timestampRe.FindAllStringSubmatchIndex(text, -1) {
+			stamp, err := timestampFrom(text, loc)
+			if err != nil {
+				continue
+			}
+			stamp.Element, stamp.Offset = i, loc[0]
+			res = append(res, stamp)
+		}
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Slugify makes a link anchor from a title, by lowercasing its letters and
+// digits and joining them with dashes.
+func Slugify(title string) string {
+	res := strings.Builder{}
+	dash := false // Whether a dash is pending between two words.
+	for _, r := range title {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = res.Len() > 0
+			continue
+		}
+		if dash {
+			res.WriteByte('-')
+			dash = false
+		}
+		res.WriteRune(unicode.ToLower(r))
+	}
+	if res.Len() == 0 {
+		return "section"
+	}
+	return res.String()
+}
+
+// slugger makes unique anchors, suffixing duplicated slugs with a number.
+type slugger map[string]int
+
+// slug returns the unique anchor of a title.
+func (s slugger) slug(title string) string {
+	res := Slugify(title)
+	for s[res] > 0 {
+		candidate := res + "-" + strconv.Itoa(s[res])
+		s[res]++
+		res = candidate
+	}
+	s[res]++
+	return res
+}
+
+// tocLink writes a link to a section in the syntax of lang.
+func tocLink(lang Language, title, slug string) (string, error) {
+	switch {
+	case slc(lang.Identifiers...).Contains(func(id string) bool { return id == "org" }):
+		// Org resolves *Title against the titles of the sections, whereas #slug
+		// would require a CUSTOM_ID property.
+		return FuseInline([]Inline{{Kind: "link", Text: title, Target: "*" + title}}), nil
+	case slc(lang.Identifiers...).Contains(func(id string) bool { return id == "markdown" }):
+		return "[" + title + "](#" + slug + ")", nil
+	}
+	return "", fmt.Errorf("no table of contents for %s", strings.Join(lang.Identifiers, "/"))
+}
+
+// GenerateTOC makes a table of contents of the sections, as a list of links
+// written for lang, which must be Org or Markdown.
+// Markdown links target the anchors of the sections, see Slugify, and Org links
+// target their titles, so that duplicated titles all link to the first one.
+// Sections deeper than maxDepth are skipped, unless it is not positive, and the
+// shallowest sections are the first level of the list.
+// No element is returned when there is no section.
+func GenerateTOC(matter Elements, maxDepth int, lang Language) (Elements, error) {
+	slugs := slugger{} // Every section is given an anchor, even when skipped.
+	links := []ListItem{}
+	levels := []int{}
+	for _, part := range matter {
+		section, ok := part.AsSection()
+		if !ok {
+			continue
+		}
+		link, err := tocLink(lang, section.Title, slugs.slug(section.Title))
+		if err != nil {
+			return nil, err
+		}
+		if maxDepth > 0 && section.Level > maxDepth {
+			continue
+		}
+		links = append(links, ListItem{Lines: []string{link}, Bullet: "-"})
+		levels = append(levels, section.Level)
+	}
+	if len(links) == 0 {
+		return Elements{}, nil
+	}
+
+	shallowest := slice[int](levels).Reduce(func(a, b int) int {
+		if b < a {
+			return b
+		}
+		return a
+	}, levels[0])
+	for i := range links {
+		links[i].Depth = levels[i] - shallowest
+	}
+	return Elements{{ElementImpl: ListElement{Items: links}}}, nil
+}
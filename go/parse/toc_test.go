@@ -0,0 +1,56 @@
+package parse_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+)
+
+func TestGenerateTOC(t *testing.T) {
+	for _, tc := range []struct {
+		lang     parse.Language
+		document []string
+		expected []string
+	}{
+		{
+			parse.MarkdownLang,
+			[]string{"# Intro", "", "## Setup", "", "### Deep", "", "# Intro"},
+			[]string{"- [Intro](#intro)", "  - [Setup](#setup)", "- [Intro](#intro-1)"},
+		},
+		{
+			parse.OrgLang,
+			[]string{"* Intro", "** Setup", "*** Deep", "* Usage"},
+			[]string{"- [[*Intro][Intro]]", "  - [[*Setup][Setup]]", "- [[*Usage][Usage]]"},
+		},
+	} {
+		matter, err := tc.lang.Parse(tc.document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toc, err := parse.GenerateTOC(matter, 2, tc.lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fused, err := tc.lang.Fuse(toc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, actual := strings.Join(tc.expected, "\n"), strings.Join(fused, "\n")
+		if expected != actual {
+			t.Errorf("%s table of contents:\n%s\nexpected:\n%s", tc.lang.Identifiers[0], actual, expected)
+		}
+
+		links := parse.ExtractLinks(toc)
+		if len(links) != len(tc.expected) {
+			t.Errorf("%s table of contents has %d links, expected %d", tc.lang.Identifiers[0], len(links), len(tc.expected))
+		}
+	}
+}
+
+func TestGenerateTOCUnsupportedLanguage(t *testing.T) {
+	matter := parse.Elements{{ElementImpl: parse.SectionElement{Level: 1, Title: "Title"}}}
+	if _, err := parse.GenerateTOC(matter, 0, parse.RSTLang); err == nil {
+		t.Errorf("no error for a language without links")
+	}
+}
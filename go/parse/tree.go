@@ -0,0 +1,201 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Node is a section of a document, along with its subsections.
+type Node struct {
+	Section  *SectionElement // Nil for the root, which holds what precedes the first section.
+	Span     Span            // Span of the section element.
+	Content  Elements        // Elements between the section and its first subsection.
+	Children []*Node
+
+	element Element // Section element the node was built from, for its other fields.
+}
+
+// BuildTree nests sections by level, a section being a child of the nearest
+// preceding section of a lower level.
+// Sections skipping levels are therefore attached to the nearest shallower
+// section.
+func BuildTree(matter Elements) (*Node, error) {
+	root := &Node{Content: Elements{}}
+	path := []*Node{root} // From the root to the current node.
+	for _, part := range matter {
+		section, ok := part.AsSection()
+		if !ok {
+			current := path[len(path)-1]
+			current.Content = append(current.Content, part)
+			continue
+		}
+		if section.Level < 1 {
+			return nil, fmt.Errorf("section `%s` has invalid level %d", section.Title, section.Level)
+		}
+
+		for len(path) > 1 && path[len(path)-1].Section.Level >= section.Level {
+			path = path[:len(path)-1]
+		}
+		node := &Node{Section: &section, Span: part.Span, Content: Elements{}, element: part}
+		parent := path[len(path)-1]
+		parent.Children = append(parent.Children, node)
+		path = append(path, node)
+	}
+	return root, nil
+}
+
+// Flatten rebuilds the sequence of elements the tree was built from.
+// Sections keep the fields of their original element, like its original lines,
+// while the modifications made to Section and Span are applied.
+func (n *Node) Flatten() Elements {
+	res := Elements{}
+	if n.Section != nil {
+		section := n.element
+		section.ElementImpl, section.Span = *n.Section, n.Span
+		res = append(res, section)
+	}
+	res = append(res, n.Content...)
+	for _, child := range n.Children {
+		res = append(res, child.Flatten()...)
+	}
+	return res
+}
+
+// Find navigates the tree by section titles, returning the first matching node
+// or nil.
+// Without titles, the node itself is returned.
+func (n *Node) Find(path ...string) *Node {
+	if len(path) == 0 {
+		return n
+	}
+	for _, child := range n.Children {
+		if child.Section.Title == path[0] {
+			if res := child.Find(path[1:]...); res != nil {
+				return res
+			}
+		}
+	}
+	return nil
+}
+
+// Select returns the nodes of the tree satisfying pred, in document order.
+// The node itself is included.
+func (n *Node) Select(pred Pred[*Node]) []*Node {
+	res := []*Node{}
+	if pred(n) {
+		res = append(res, n)
+	}
+	for _, child := range n.Children {
+		res = append(res, child.Select(pred)...)
+	}
+	return res
+}
+
+// NormalizeLevels renumbers sections so that subsections are exactly one level
+// deeper than their parent, the first level being 1.
+// Sections are nested like with BuildTree and the receiver is not modified.
+func NormalizeLevels(matter Elements) Elements {
+	res := make(Elements, len(matter))
+	path := []int{} // Original levels of the current section and its ancestors.
+	for i, part := range matter {
+		res[i] = part
+		section, ok := part.AsSection()
+		if !ok {
+			continue
+		}
+		for len(path) > 0 && path[len(path)-1] >= section.Level {
+			path = path[:len(path)-1]
+		}
+		path = append(path, section.Level)
+		section.Level = len(path)
+		res[i].ElementImpl = section
+	}
+	return res
+}
+
+// ShiftLevels adds delta to the level of every section, to promote or demote a
+// whole document or a flattened subtree.
+// The receiver is not modified.
+func ShiftLevels(matter Elements, delta int) (Elements, error) {
+	res := make(Elements, len(matter))
+	for i, part := range matter {
+		res[i] = part
+		section, ok := part.AsSection()
+		if !ok {
+			continue
+		}
+		if section.Level+delta < 1 {
+			return nil, fmt.Errorf("section `%s` cannot be shifted from level %d to %d", section.Title, section.Level, section.Level+delta)
+		}
+		section.Level += delta
+		res[i].ElementImpl = section
+	}
+	return res, nil
+}
+
+// SplitBySection cuts the document at the sections of the given level, keying
+// each section by its title, along with its subsections.
+// What is not within a section of that level, like the content preceding the
+// first one, is kept under the empty key.
+// Sections sharing a title are concatenated in document order.
+func SplitBySection(matter Elements, level int) map[string]Elements {
+	res := map[string]Elements{}
+	key := ""
+	for _, part := range matter {
+		if section, ok := part.AsSection(); ok && section.Level <= level {
+			key = ""
+			if section.Level == level {
+				key = section.Title
+			}
+		}
+		res[key] = append(res[key], part)
+	}
+	return res
+}
+
+// Merge concatenates documents.
+// The spans of the elements are kept, so they refer to their own document.
+func Merge(docs ...Elements) Elements {
+	res := Elements{}
+	for _, doc := range docs {
+		res = append(res, doc...)
+	}
+	return res
+}
+
+// MergeUnder concatenates documents under a new level 1 section, their own
+// sections being demoted by one level.
+func MergeUnder(title string, docs ...Elements) Elements {
+	res := Elements{{ElementImpl: SectionElement{Level: 1, Title: title}}}
+	for _, doc := range docs {
+		demoted, _ := ShiftLevels(doc, 1) // Demoting cannot fail.
+		res = append(res, demoted...)
+	}
+	return res
+}
+
+// Tree writes an outline of the elements to w for debugging purposes, each
+// element being indented below its section and summarised by its kind and its
+// label, see Change.String.
+// Whitespace is skipped.
+func (ps Elements) Tree(w io.Writer) {
+	path := []int{} // Levels of the current section and its ancestors.
+	for _, part := range ps {
+		depth := len(path)
+		switch p := part.ElementImpl.(type) {
+		case SpaceElement:
+			continue
+		case SectionElement:
+			for len(path) > 0 && path[len(path)-1] >= p.Level {
+				path = path[:len(path)-1]
+			}
+			depth = len(path)
+			path = append(path, p.Level)
+		case CodeElement:
+			fmt.Fprintf(w, "%s%s %s `%s`\n", strings.Repeat("  ", depth), part.TypeName(), p.Lang, diffLabel(part))
+			continue
+		}
+		fmt.Fprintf(w, "%s%s `%s`\n", strings.Repeat("  ", depth), part.TypeName(), diffLabel(part))
+	}
+}
@@ -0,0 +1,42 @@
+package parse_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+	"github.com/mooss/litlib/parse/parsetest"
+)
+
+func TestTreeFlattenKeepsElements(t *testing.T) {
+	input := []string{
+		"Preamble.",
+		"* Tagged    :tag:",
+		"Text.",
+		"** Child",
+		"* Renamed",
+		"Text.",
+	}
+	matter, err := parse.OrgLang.Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := parse.BuildTree(matter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flat := tree.Flatten(); !reflect.DeepEqual(flat, matter) {
+		t.Fatalf("flattened into %v, expected %v", parsetest.Repr(flat), parsetest.Repr(matter))
+	}
+
+	tree.Find("Renamed").Section.Title = "New title"
+	fused, err := parse.OrgLang.FuseOriginal(tree.Flatten())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append(append([]string{}, input[:4]...), "* New title", "Text.")
+	if !reflect.DeepEqual(fused, expected) {
+		t.Errorf("renamed section fused into\n%s\nexpected\n%s", strings.Join(fused, "\n"), strings.Join(expected, "\n"))
+	}
+}
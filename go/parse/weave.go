@@ -0,0 +1,156 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WeaveOptions configures Weave.
+type WeaveOptions struct {
+	Default string // Exports value of the blocks without one, code when empty.
+
+	// Target backend, like html, the export blocks and snippets of other
+	// backends being dropped; all are kept when empty.
+	Backend string
+}
+
+// exportSnippetRe matches the Org export snippets, like `@@html:<br>@@`,
+// capturing their backend.
+var exportSnippetRe = re(`@@([[:alnum:]-]+):.*?@@`)
+
+// weaveSnippets drops the export snippets of the prose that are not meant for
+// backend, unless it is empty.
+func weaveSnippets(part Element, backend string) Element {
+	prose, ok := part.AsProse()
+	if !ok || backend == "" || !slc(prose.Raw...).Contains(exportSnippetRe.Match) {
+		return part
+	}
+	raw := make([]string, len(prose.Raw))
+	for i, line := range prose.Raw {
+		raw[i] = exportSnippetRe.ReplaceFunc(line, func(groups []string) string {
+			if strings.EqualFold(groups[1], backend) {
+				return groups[0]
+			}
+			return ""
+		})
+	}
+	part.ElementImpl = ProseElement{Raw: raw}
+	return part
+}
+
+// resultsSpan returns the number of elements forming the results of a code
+// block, given the elements that follow it, as well as the index of the
+// content of the results or -1.
+// Results are introduced by a RESULTS keyword, possibly preceded by whitespace,
+// and their content is the element directly following the keyword.
+func resultsSpan(following Elements) (span, content int) {
+	for i, part := range following {
+		switch p := part.ElementImpl.(type) {
+		case SpaceElement:
+			continue
+		case MetadataElement:
+			if !strings.EqualFold(p.Name, "results") {
+				return 0, -1
+			}
+			if i+1 < len(following) {
+				if _, space := following[i+1].ElementImpl.(SpaceElement); !space {
+					return i + 2, i + 1
+				}
+			}
+			return i + 1, -1
+		}
+		return 0, -1
+	}
+	return 0, -1
+}
+
+// Weave prepares a document for human consumption by applying the exports
+// parameter of code blocks, following Org semantics:
+//   - code keeps the code and drops its results.
+//   - results drops the code and keeps its results.
+//   - both keeps the code and its results.
+//   - none drops the code and its results.
+//
+// The RESULTS keyword is dropped, only the content of the results is kept,
+// whether it follows the code block or is held in CodeElement.Results.
+// When a backend is given, the exports meant for other backends are dropped.
+// The given elements are not modified.
+func Weave(matter Elements, opts WeaveOptions) (Elements, error) {
+	def := opts.Default
+	if def == "" {
+		def = "code"
+	}
+
+	res := Elements{}
+	for i := 0; i < len(matter); i++ {
+		if export, ok := matter[i].ElementImpl.(ExportElement); ok && opts.Backend != "" {
+			if strings.EqualFold(export.Backend, opts.Backend) {
+				res = append(res, matter[i])
+			}
+			continue
+		}
+		code, ok := matter[i].ElementImpl.(CodeElement)
+		if !ok {
+			res = append(res, weaveSnippets(matter[i], opts.Backend))
+			continue
+		}
+
+		exports := def
+		if vp := code.Params.Get("exports"); vp != nil && len(*vp) > 0 {
+			exports = (*vp)[0]
+		}
+		span, content := resultsSpan(matter[i+1:])
+		var keepCode, keepResults bool
+		switch exports {
+		case "code":
+			keepCode = true
+		case "results":
+			keepResults = true
+		case "both":
+			keepCode, keepResults = true, true
+		case "none":
+		default:
+			return nil, fmt.Errorf("unknown exports value `%s` for code block %d", exports, i)
+		}
+
+		if code.Results != nil { // Results attached by a refiner, like OrgResultsRefiner.
+			woven, err := weaveResults(matter[i], keepCode, keepResults)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, woven...)
+			continue
+		}
+		if keepCode {
+			res = append(res, matter[i])
+		}
+		if keepResults && content != -1 {
+			res = append(res, matter[i+1+content])
+		}
+		i += span
+	}
+	return res, nil
+}
+
+// weaveResults applies the exports parameter to a code block holding its
+// results, which are parsed as Org since only Org attaches them.
+func weaveResults(part Element, keepCode, keepResults bool) (Elements, error) {
+	code := part.ElementImpl.(CodeElement)
+	res := Elements{}
+	if keepCode {
+		stripped, bare := part, code
+		bare.Results = nil
+		stripped.ElementImpl = bare
+		stripped.Span.EndLine -= len(code.Results) + 2 // Blank and RESULTS lines.
+		stripped.Original = nil
+		res = append(res, stripped)
+	}
+	if keepResults {
+		content, err := OrgRules.parseFrom(code.Results, part.Span.EndLine-len(code.Results)+1)
+		if err != nil {
+			return nil, fmt.Errorf("results of code block on line %d: %w", part.Span.StartLine, err)
+		}
+		res = append(res, content...)
+	}
+	return res, nil
+}
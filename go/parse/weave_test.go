@@ -0,0 +1,55 @@
+package parse_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mooss/litlib/parse"
+)
+
+func TestWeaveExports(t *testing.T) {
+	block := func(exports string) []string {
+		return []string{
+			"#+begin_src sh :exports " + exports,
+			"echo hello",
+			"#+end_src",
+			"",
+			"#+RESULTS:",
+			": hello",
+		}
+	}
+	code := []string{"#+begin_src sh :exports %s", "echo hello", "#+end_src"}
+	results := []string{": hello"}
+
+	for exports, expected := range map[string][]string{
+		"code":    code,
+		"results": results,
+		"both":    append(append([]string{}, code...), results...),
+		"none":    {},
+	} {
+		t.Run(exports, func(t *testing.T) {
+			matter, err := parse.OrgLang.Parse(block(exports))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code, ok := matter[0].AsCode(); !ok || code.Results == nil {
+				t.Fatalf("results are not attached to the code block: %v", matter[0].Repr())
+			}
+			woven, err := parse.Weave(matter, parse.WeaveOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fused, err := parse.OrgLang.Fuse(woven)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.ReplaceAll(strings.Join(expected, "\n"), "%s", exports)
+			if got := strings.Join(fused, "\n"); got != want {
+				t.Errorf("exports %s woven into:\n%s\nexpected:\n%s", exports, got, want)
+			}
+			if code, _ := matter[0].AsCode(); code.Results == nil {
+				t.Errorf("the results of the given elements were cleared")
+			}
+		})
+	}
+}